@@ -6,6 +6,8 @@ require (
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/gorilla/mux v1.8.0
 	github.com/iancoleman/strcase v0.2.0
+	github.com/lib/pq v1.10.2
 	github.com/spf13/cobra v1.2.1
 	github.com/stretchr/testify v1.7.0
+	modernc.org/sqlite v1.14.6
 )
@@ -0,0 +1,128 @@
+package apis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MaxBatchSize is the maximum number of books of a batch
+const MaxBatchSize = 10000
+
+// ErrBatchTooLarge is returned when a batch holds more than MaxBatchSize books
+var ErrBatchTooLarge = fmt.Errorf("a batch cannot contain more than %d books", MaxBatchSize)
+
+// BatchMode controls how a batch behaves when some of its items fail
+type BatchMode string
+
+const (
+	// Atomic applies either all the items of the batch or none of them
+	Atomic BatchMode = "atomic"
+	// BestEffort applies the valid items even if others fail
+	BestEffort BatchMode = "best-effort"
+)
+
+// ParseBatchMode returns the batch mode named by s. The empty string selects Atomic.
+func ParseBatchMode(s string) (BatchMode, error) {
+	switch BatchMode(s) {
+	case "", Atomic:
+		return Atomic, nil
+	case BestEffort:
+		return BestEffort, nil
+	default:
+		return "", fmt.Errorf("invalid batch mode: %v", s)
+	}
+}
+
+// BatchStatus is the outcome of a single item of a batch
+type BatchStatus string
+
+const (
+	BatchCreated BatchStatus = "created"
+	BatchUpdated BatchStatus = "updated"
+	BatchFailed  BatchStatus = "failed"
+)
+
+// BatchResult reports the outcome of a single item of a batch
+type BatchResult struct {
+	Isbn   string      `json:"isbn"`
+	Status BatchStatus `json:"status"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// BatchReport reports the outcome of every item of a batch, in the order they were sent
+type BatchReport struct {
+	Mode      BatchMode     `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// DecodeBooks reads either a JSON array of books or a stream of newline delimited JSON books.
+// It stops with ErrBatchTooLarge as soon as it reads more than MaxBatchSize books, without reading the rest.
+func DecodeBooks(r io.Reader) ([]Book, error) {
+	reader := bufio.NewReader(r)
+
+	// skip the leading white spaces to tell an array from a stream
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return []Book{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			break
+		}
+		reader.ReadByte()
+	}
+
+	decoder := json.NewDecoder(reader)
+	books := []Book{}
+
+	if b, _ := reader.Peek(1); b[0] == '[' {
+		// the opening bracket
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		for decoder.More() {
+			if len(books) == MaxBatchSize {
+				return nil, ErrBatchTooLarge
+			}
+
+			book := Book{}
+			if err := decoder.Decode(&book); err != nil {
+				return nil, fmt.Errorf("book %d: %v", len(books)+1, err)
+			}
+			books = append(books, book)
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		if decoder.More() {
+			return nil, fmt.Errorf("unexpected data after the array of books")
+		}
+		return books, nil
+	}
+
+	for {
+		book := Book{}
+		err := decoder.Decode(&book)
+		if err == io.EOF {
+			return books, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("book %d: %v", len(books)+1, err)
+		}
+
+		if len(books) == MaxBatchSize {
+			return nil, ErrBatchTooLarge
+		}
+		books = append(books, book)
+	}
+}
@@ -0,0 +1,116 @@
+package apis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL flavour spoken by a database when filters are converted into SQL statements
+type Dialect interface {
+	// Name returns the name of the dialect
+	Name() string
+	// Placeholder returns the bind parameter for the n-th value of a statement, starting from 1
+	Placeholder(n int) string
+	// Symbol returns the SQL operator used for the filter operation
+	Symbol(op Operator) string
+	// LimitOffset returns the clause restricting the rows of a query. A zero limit means no limit.
+	LimitOffset(limit int, offset int) string
+}
+
+var (
+	// MySQL is the dialect spoken by MySQL. It uses `?` placeholders
+	MySQL Dialect = mysqlDialect{}
+	// SQLite is the dialect spoken by SQLite. It uses `?` placeholders
+	SQLite Dialect = sqliteDialect{}
+	// Postgres is the dialect spoken by PostgreSQL. It uses numbered `$n` placeholders
+	Postgres Dialect = postgresDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (mysqlDialect) Symbol(op Operator) string {
+	return op.Symbol()
+}
+
+func (mysqlDialect) LimitOffset(limit int, offset int) string {
+	switch {
+	case limit > 0:
+		return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	case offset > 0:
+		// MySQL does not support OFFSET without LIMIT
+		return fmt.Sprintf("LIMIT 18446744073709551615 OFFSET %d", offset)
+	}
+	return ""
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (sqliteDialect) Symbol(op Operator) string {
+	return op.Symbol()
+}
+
+func (sqliteDialect) LimitOffset(limit int, offset int) string {
+	switch {
+	case limit > 0:
+		return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	case offset > 0:
+		// a negative limit means no limit in SQLite
+		return fmt.Sprintf("LIMIT -1 OFFSET %d", offset)
+	}
+	return ""
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Symbol returns ILIKE for the pattern operators, so that they ignore case as in the other databases
+func (postgresDialect) Symbol(op Operator) string {
+	if op.Pattern() {
+		return "ILIKE"
+	}
+	return op.Symbol()
+}
+
+func (postgresDialect) LimitOffset(limit int, offset int) string {
+	var clauses []string
+	if limit > 0 {
+		clauses = append(clauses, fmt.Sprintf("LIMIT %d", limit))
+	}
+	if offset > 0 {
+		clauses = append(clauses, fmt.Sprintf("OFFSET %d", offset))
+	}
+	return strings.Join(clauses, " ")
+}
+
+// Placeholders returns n comma separated bind parameters numbered after offset
+func Placeholders(d Dialect, offset int, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = d.Placeholder(offset + i + 1)
+	}
+	return strings.Join(params, ", ")
+}
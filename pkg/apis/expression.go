@@ -0,0 +1,324 @@
+package apis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AndFilter is satisfied by the resources satisfying all of its filters. It groups a conjunction nested in another expression, e.g. in an OrFilter.
+type AndFilter struct {
+	Filters []SQLConverter
+}
+
+// FieldName returns an empty string, since the filter may involve several fields
+func (a *AndFilter) FieldName() string {
+	return ""
+}
+
+// String returns the filter in the form it is parsed from, enclosed in parentheses
+func (a *AndFilter) String() string {
+	return "(" + joinFilters(a.Filters, And) + ")"
+}
+
+func (a *AndFilter) SQL() (string, []interface{}) {
+	return a.DialectSQL(MySQL, 0)
+}
+
+func (a *AndFilter) DialectSQL(d Dialect, offset int) (string, []interface{}) {
+	prepare, query := joinSQL(a.Filters, d, offset, " AND ")
+	return "(" + prepare + ")", query
+}
+
+func (a *AndFilter) Match(resource interface{}) bool {
+	for _, f := range a.Filters {
+		if !f.Match(resource) {
+			return false
+		}
+	}
+	return true
+}
+
+// OrFilter is satisfied by the resources satisfying any of its filters
+type OrFilter struct {
+	Filters []SQLConverter
+}
+
+// FieldName returns an empty string, since the filter may involve several fields
+func (o *OrFilter) FieldName() string {
+	return ""
+}
+
+// String returns the filter in the form it is parsed from, enclosed in parentheses
+func (o *OrFilter) String() string {
+	return "(" + joinFilters(o.Filters, Or) + ")"
+}
+
+func (o *OrFilter) SQL() (string, []interface{}) {
+	return o.DialectSQL(MySQL, 0)
+}
+
+func (o *OrFilter) DialectSQL(d Dialect, offset int) (string, []interface{}) {
+	prepare, query := joinSQL(o.Filters, d, offset, " OR ")
+	return "(" + prepare + ")", query
+}
+
+func (o *OrFilter) Match(resource interface{}) bool {
+	for _, f := range o.Filters {
+		if f.Match(resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// NotFilter is satisfied by the resources not satisfying its filter
+type NotFilter struct {
+	Filter SQLConverter
+}
+
+// FieldName returns an empty string, since the filter may involve several fields
+func (n *NotFilter) FieldName() string {
+	return ""
+}
+
+// String returns the filter in the form it is parsed from
+func (n *NotFilter) String() string {
+	return Not.String() + "_" + n.Filter.String()
+}
+
+func (n *NotFilter) SQL() (string, []interface{}) {
+	return n.DialectSQL(MySQL, 0)
+}
+
+func (n *NotFilter) DialectSQL(d Dialect, offset int) (string, []interface{}) {
+	prepare, query := n.Filter.DialectSQL(d, offset)
+	return "NOT (" + prepare + ")", query
+}
+
+func (n *NotFilter) Match(resource interface{}) bool {
+	return !n.Filter.Match(resource)
+}
+
+// joinFilters joins the string forms of the filters with the logical operator
+func joinFilters(filters []SQLConverter, op Operator) string {
+	parts := make([]string, len(filters))
+	for i, f := range filters {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, "_"+op.String()+"_")
+}
+
+// joinSQL converts the filters into SQL statements for the dialect and joins them with the separator. Placeholders are numbered starting after offset.
+func joinSQL(filters []SQLConverter, d Dialect, offset int, separator string) (prepare string, query []interface{}) {
+	prepares := make([]string, len(filters))
+	for i, f := range filters {
+		var values []interface{}
+		prepares[i], values = f.DialectSQL(d, offset+len(query))
+		query = append(query, values...)
+	}
+	return strings.Join(prepares, separator), query
+}
+
+// comparisonParserFunc builds the filter comparing a field with a value, e.g. from the tokens `title`, `eq` and `romeo-and-juliet`
+type comparisonParserFunc func(field string, operator string, value string) (SQLConverter, error)
+
+// comparisonCheckFunc reports whether a comparison of the field with the operator is valid, whatever its value
+type comparisonCheckFunc func(field string, operator string) bool
+
+// filterParser parses a filter string into an expression tree following the grammar
+//
+//	expression := term { "or" term }
+//	term       := factor { "and" factor }
+//	factor     := "not" factor | "(" expression ")" | comparison
+//	comparison := field operator value
+//
+// Tokens are separated by '_', while parentheses need no separator, e.g. `not_(genre_eq_drama_or_edition_gt_2)`.
+// The value spans the tokens up to the next parenthesis, or the next `and` or `or` starting another factor: a `not`, a parenthesis or a valid
+// field and operator. Any other `and` or `or` is part of the value, e.g. `title_eq_romeo_and_juliet`, as are single underscores,
+// e.g. `genre_eq_sci_fi`. A value holding an `_and_` or an `_or_` followed by what reads like a comparison must still be encoded with EncodeFilterValue.
+type filterParser struct {
+	tokens     []string
+	pos        int
+	comparison comparisonParserFunc
+	// isComparison tells the comparisons following an `and` or an `or` from the rest of a value
+	isComparison comparisonCheckFunc
+}
+
+// tokenize splits a filter string into its tokens: the words between underscores and the parentheses
+func tokenize(filters string) []string {
+	var tokens []string
+
+	for _, word := range strings.Split(filters, "_") {
+		trimmed := strings.TrimLeft(word, "(")
+		for i := len(trimmed); i < len(word); i++ {
+			tokens = append(tokens, "(")
+		}
+
+		inner := strings.TrimRight(trimmed, ")")
+		// an empty word is kept as an empty value, unless it only separates parentheses
+		if inner != "" || inner == word {
+			tokens = append(tokens, inner)
+		}
+
+		for i := len(inner); i < len(trimmed); i++ {
+			tokens = append(tokens, ")")
+		}
+	}
+
+	return tokens
+}
+
+// parseExpression parses a filter string into an expression tree, building the comparisons with the function comparison. isComparison checks
+// the field and the operator of the comparisons, so that the `and` and the `or` followed by an invalid one are read as part of a value.
+func parseExpression(filters string, comparison comparisonParserFunc, isComparison comparisonCheckFunc) (SQLConverter, error) {
+	p := &filterParser{tokens: tokenize(filters), comparison: comparison, isComparison: isComparison}
+
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := p.peek(); ok {
+		return nil, fmt.Errorf(ErrInvalidFilter, fmt.Sprintf("unexpected %q", token))
+	}
+	return root, nil
+}
+
+// peek returns the next token without consuming it. The boolean is false at the end of the filter.
+func (p *filterParser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is the expected one
+func (p *filterParser) accept(expected string) bool {
+	if token, ok := p.peek(); ok && token == expected {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) expression() (SQLConverter, error) {
+	var terms []SQLConverter
+	for {
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+
+		if !p.accept(Or.String()) {
+			break
+		}
+	}
+
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &OrFilter{Filters: terms}, nil
+}
+
+func (p *filterParser) term() (SQLConverter, error) {
+	var factors []SQLConverter
+	for {
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		factors = append(factors, factor)
+
+		if !p.accept(And.String()) {
+			break
+		}
+	}
+
+	if len(factors) == 1 {
+		return factors[0], nil
+	}
+	return &AndFilter{Filters: factors}, nil
+}
+
+func (p *filterParser) factor() (SQLConverter, error) {
+	if p.accept(Not.String()) {
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &NotFilter{Filter: factor}, nil
+	}
+
+	if p.accept("(") {
+		expression, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf(ErrInvalidFilter, "missing closing parenthesis")
+		}
+		return expression, nil
+	}
+
+	return p.comparisonFactor()
+}
+
+// comparisonFactor parses a field, an operator and the value following them
+func (p *filterParser) comparisonFactor() (SQLConverter, error) {
+	var parts []string
+	for len(parts) < 2 {
+		token, ok := p.peek()
+		if !ok || token == "(" || token == ")" {
+			return nil, fmt.Errorf(ErrInvalidFilter, "wrong number of filter parts")
+		}
+		parts = append(parts, token)
+		p.pos++
+	}
+
+	// the first token of the value is always taken, even if it reads like a keyword, e.g. title_eq_or
+	token, ok := p.peek()
+	if !ok || token == "(" || token == ")" {
+		return nil, fmt.Errorf(ErrInvalidFilter, "wrong number of filter parts")
+	}
+	value := []string{token}
+	p.pos++
+
+	for {
+		token, ok := p.peek()
+		if !ok || token == "(" || token == ")" {
+			break
+		}
+		// an `and` or an `or` not followed by another factor backtracks into the value, e.g. title_eq_romeo_and_juliet
+		if (token == And.String() || token == Or.String()) && p.startsFactor(p.pos+1) {
+			break
+		}
+		value = append(value, token)
+		p.pos++
+	}
+
+	return p.comparison(parts[0], parts[1], strings.Join(value, "_"))
+}
+
+// startsFactor reports whether the tokens from pos start a factor: a `not` followed by a factor, an opening parenthesis,
+// or a valid field and operator
+func (p *filterParser) startsFactor(pos int) bool {
+	if pos >= len(p.tokens) {
+		return false
+	}
+
+	switch p.tokens[pos] {
+	case Not.String():
+		return p.startsFactor(pos + 1)
+	case "(":
+		return true
+	case ")":
+		return false
+	}
+
+	// a missing value is reported by the comparison rather than read as part of the previous one, e.g. genre_eq_drama_or_edition_eq
+	if pos+1 >= len(p.tokens) {
+		return false
+	}
+	return p.isComparison(p.tokens[pos], p.tokens[pos+1])
+}
@@ -3,7 +3,11 @@ package apis
 import (
 	"container/list"
 	"fmt"
+	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/iancoleman/strcase"
@@ -13,9 +17,19 @@ import (
 type Operator string
 
 const (
-	And      Operator = "and"
-	Equals   Operator = "eq"
-	NotEqual Operator = "ne"
+	And            Operator = "and"
+	Or             Operator = "or"
+	Not            Operator = "not"
+	Equals         Operator = "eq"
+	NotEqual       Operator = "ne"
+	LessThan       Operator = "lt"
+	LessOrEqual    Operator = "le"
+	GreaterThan    Operator = "gt"
+	GreaterOrEqual Operator = "ge"
+	Contains       Operator = "contains"
+	StartsWith     Operator = "startswith"
+	EndsWith       Operator = "endswith"
+	In             Operator = "in"
 
 	ErrInvalidFilter string = "invalid filter: %v"
 )
@@ -33,19 +47,78 @@ func (o Operator) Symbol() (symbol string) {
 		symbol = "="
 	case NotEqual:
 		symbol = "<>"
+	case LessThan:
+		symbol = "<"
+	case LessOrEqual:
+		symbol = "<="
+	case GreaterThan:
+		symbol = ">"
+	case GreaterOrEqual:
+		symbol = ">="
+	case Contains, StartsWith, EndsWith:
+		symbol = "LIKE"
+	case In:
+		symbol = "IN"
 	}
 	return
 }
 
+// inSeparator separates the values of the in operator, e.g. genre_in_drama|comedy
+const inSeparator = "|"
+
+// Pattern reports whether the operator matches the text fields against a pattern built from the value
+func (o Operator) Pattern() bool {
+	switch o {
+	case Contains, StartsWith, EndsWith:
+		return true
+	default:
+		return false
+	}
+}
+
+// likeEscape is the escape character of the LIKE patterns. Unlike the backslash, it needs no quoting in the SQL strings of any dialect.
+const likeEscape = "!"
+
+// likePattern returns the LIKE pattern matching the value as the pattern operator does. The wildcards in the value are escaped, so that they match literally.
+func likePattern(op Operator, value string) string {
+	value = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_").Replace(value)
+
+	switch op {
+	case StartsWith:
+		return value + "%"
+	case EndsWith:
+		return "%" + value
+	default:
+		return "%" + value + "%"
+	}
+}
+
+// Ordering reports whether the operator compares the order of the values, which is only defined for numeric and date fields
+func (o Operator) Ordering() bool {
+	switch o {
+	case LessThan, LessOrEqual, GreaterThan, GreaterOrEqual:
+		return true
+	default:
+		return false
+	}
+}
+
 type fieldValidatorFunc func(field string) bool
 type valueValidatorFunc func(field string, value string) bool
+type operatorValidatorFunc func(field string, op Operator) bool
 
 // SQLConverter converts a filter into a SQL statement.
 type SQLConverter interface {
 	// SQL returns the SQL query and the parameters to bind to it.
 	SQL() (prepare string, query []interface{})
+	// DialectSQL returns the SQL query written for the dialect and the parameters to bind to it. Placeholders are numbered starting after offset.
+	DialectSQL(d Dialect, offset int) (prepare string, query []interface{})
+	// Match evaluates the filter against a resource (e.g. a Book or a pointer to it) without involving a database
+	Match(resource interface{}) bool
 	// FieldName returns the resource field name
 	FieldName() string
+	// String returns the filter in the form it is parsed from
+	String() string
 }
 
 // Filter is a filter for a field and an operation
@@ -59,8 +132,48 @@ func (f *Filter) FieldName() string {
 	return f.Field
 }
 
+// String returns the filter in the form it is parsed from, with the field in kebab case and the value encoded
+func (f *Filter) String() string {
+	return strcase.ToKebab(f.Field) + "_" + f.Operation.String() + "_" + EncodeFilterValue(f.Value)
+}
+
 func (f *Filter) SQL() (string, []interface{}) {
-	return strcase.ToSnake(f.Field) + " " + f.Operation.Symbol() + " ?", []interface{}{f.Value}
+	return f.DialectSQL(MySQL, 0)
+}
+
+func (f *Filter) DialectSQL(d Dialect, offset int) (string, []interface{}) {
+	if f.Operation.Pattern() {
+		return strcase.ToSnake(f.Field) + " " + d.Symbol(f.Operation) + " " + d.Placeholder(offset+1) + " ESCAPE '" + likeEscape + "'", []interface{}{likePattern(f.Operation, f.Value)}
+	}
+	return strcase.ToSnake(f.Field) + " " + d.Symbol(f.Operation) + " " + d.Placeholder(offset+1), []interface{}{f.Value}
+}
+
+func (f *Filter) Match(resource interface{}) bool {
+	if f.Operation.Pattern() {
+		return matchPattern(resource, f.Field, f.Operation, f.Value)
+	}
+
+	cmp, ok := compareField(resource, f.Field, f.Value)
+	if !ok {
+		return false
+	}
+
+	switch f.Operation {
+	case Equals:
+		return cmp == 0
+	case NotEqual:
+		return cmp != 0
+	case LessThan:
+		return cmp < 0
+	case LessOrEqual:
+		return cmp <= 0
+	case GreaterThan:
+		return cmp > 0
+	case GreaterOrEqual:
+		return cmp >= 0
+	default:
+		return false
+	}
 }
 
 // DateRangeFilter is a filter for a date range
@@ -70,14 +183,143 @@ type DateRangeFilter struct {
 	EndDate   string
 }
 
+// String returns the filter in the form it is parsed from
+func (d *DateRangeFilter) String() string {
+	return "dates_" + Equals.String() + "_" + EncodeFilterValue(d.StartDate) + "-to-" + EncodeFilterValue(d.EndDate)
+}
+
 func (d *DateRangeFilter) SQL() (string, []interface{}) {
-	return strcase.ToSnake(d.Field) + " >= ? AND " + d.Field + " <= ?", []interface{}{d.StartDate, d.EndDate}
+	return d.DialectSQL(MySQL, 0)
+}
+
+func (d *DateRangeFilter) DialectSQL(dialect Dialect, offset int) (string, []interface{}) {
+	field := strcase.ToSnake(d.Field)
+	return field + " >= " + dialect.Placeholder(offset+1) + " AND " + field + " <= " + dialect.Placeholder(offset+2), []interface{}{d.StartDate, d.EndDate}
+}
+
+func (d *DateRangeFilter) Match(resource interface{}) bool {
+	field := strcase.ToCamel(d.Field)
+
+	afterStart, ok := compareField(resource, field, d.StartDate)
+	if !ok {
+		return false
+	}
+
+	beforeEnd, ok := compareField(resource, field, d.EndDate)
+	if !ok {
+		return false
+	}
+
+	return afterStart >= 0 && beforeEnd <= 0
 }
 
 func (d *DateRangeFilter) FieldName() string {
 	return d.Field
 }
 
+// InFilter is a filter selecting the resources whose field equals any of the values
+type InFilter struct {
+	Field  string
+	Values []string
+}
+
+func (i *InFilter) FieldName() string {
+	return i.Field
+}
+
+// String returns the filter in the form it is parsed from, with the field in kebab case and the values encoded
+func (i *InFilter) String() string {
+	values := make([]string, len(i.Values))
+	for n, value := range i.Values {
+		values[n] = EncodeFilterValue(value)
+	}
+	return strcase.ToKebab(i.Field) + "_" + In.String() + "_" + strings.Join(values, inSeparator)
+}
+
+func (i *InFilter) SQL() (string, []interface{}) {
+	return i.DialectSQL(MySQL, 0)
+}
+
+func (i *InFilter) DialectSQL(d Dialect, offset int) (string, []interface{}) {
+	placeholders := make([]string, len(i.Values))
+	values := make([]interface{}, len(i.Values))
+	for n, value := range i.Values {
+		placeholders[n] = d.Placeholder(offset + n + 1)
+		values[n] = value
+	}
+	return strcase.ToSnake(i.Field) + " " + d.Symbol(In) + " (" + strings.Join(placeholders, ", ") + ")", values
+}
+
+func (i *InFilter) Match(resource interface{}) bool {
+	for _, value := range i.Values {
+		if cmp, ok := compareField(resource, i.Field, value); ok && cmp == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern reports whether the named text field of a resource matches the value as the pattern operator does, ignoring case like the database
+func matchPattern(resource interface{}, field string, op Operator, value string) bool {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+
+	fv := v.FieldByName(field)
+	if !fv.IsValid() || fv.Kind() != reflect.String {
+		return false
+	}
+
+	text, value := strings.ToLower(fv.String()), strings.ToLower(value)
+	switch op {
+	case StartsWith:
+		return strings.HasPrefix(text, value)
+	case EndsWith:
+		return strings.HasSuffix(text, value)
+	default:
+		return strings.Contains(text, value)
+	}
+}
+
+// compareField compares the named field of a resource with a filter value and returns -1, 0 or +1 like strings.Compare.
+// Strings are compared ignoring case to behave like the default collation of the database. The boolean is false if the field does not exist or the value cannot be compared.
+func compareField(resource interface{}, field string, value string) (int, bool) {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+
+	fv := v.FieldByName(field)
+	if !fv.IsValid() {
+		return 0, false
+	}
+
+	switch fv.Type() {
+	case reflect.TypeOf(Date{}):
+		return strings.Compare(fv.Interface().(Date).String(), value), true
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		return strings.Compare(strings.ToLower(fv.String()), strings.ToLower(value)), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case fv.Uint() < num:
+			return -1, true
+		case fv.Uint() > num:
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
 // FilterChain is a chain of filters
 type FilterChain struct {
 	chain *list.List
@@ -103,12 +345,43 @@ func (f *FilterChain) add(filter SQLConverter) *FilterChain {
 	return f
 }
 
-// SQLStatement converts all filters in their corresponding SQL statement.
+// And appends a filter that the resources must satisfy too
+func (f *FilterChain) And(filter SQLConverter) *FilterChain {
+	return f.add(filter)
+}
+
+// Match reports whether a resource satisfies all the filters of the chain.
+func (f *FilterChain) Match(resource interface{}) bool {
+	for e := f.chain.Front(); e != nil; e = e.Next() {
+		if !e.Value.(SQLConverter).Match(resource) {
+			return false
+		}
+	}
+	return true
+}
+
+// Canonical returns a string identifying the resources selected by the chain: chains made of the same filters in any order have the same canonical form
+func (f *FilterChain) Canonical() string {
+	var filters []string
+	for e := f.chain.Front(); e != nil; e = e.Next() {
+		filters = append(filters, e.Value.(SQLConverter).String())
+	}
+
+	sort.Strings(filters)
+	return strings.Join(filters, "_"+And.String()+"_")
+}
+
+// SQLStatement converts all filters in their corresponding MySQL statement.
 func (f *FilterChain) SQLStatement() (prepare string, query []interface{}) {
+	return f.DialectSQLStatement(MySQL, 0)
+}
+
+// DialectSQLStatement converts all filters in their corresponding SQL statement for the dialect. Placeholders are numbered starting after offset.
+func (f *FilterChain) DialectSQLStatement(d Dialect, offset int) (prepare string, query []interface{}) {
 	var prepares []string
 
 	for e := f.chain.Front(); e != nil; e = e.Next() {
-		prepare, values := e.Value.(SQLConverter).SQL()
+		prepare, values := e.Value.(SQLConverter).DialectSQL(d, offset+len(query))
 		prepares = append(prepares, prepare)
 		query = append(query, values...)
 	}
@@ -116,9 +389,20 @@ func (f *FilterChain) SQLStatement() (prepare string, query []interface{}) {
 	return strings.Join(prepares, " AND "), query
 }
 
-// ParseFilters build a filterchain from a string. If anything goes wrong, it returns an ErrInvalidFilter. It requires a fieldvalidator and a valuevalidator to perform type checking on the struct field.
+// ParseFilters build a book filterchain from a string. If anything goes wrong, it returns an ErrInvalidFilter. It requires a fieldvalidator and a valuevalidator to perform type checking on the struct field.
+// The operators are checked against the book fields with ValidateBookOperator.
 func ParseFilters(filters string, validateField fieldValidatorFunc, validateValue valueValidatorFunc) (chain *FilterChain, err error) {
-	filterChain, err := parseFilters(filters, validateField, validateValue)
+	return parseResourceFilters(filters, BookType.Identifier(), BookType.DateField(), validateField, validateValue, ValidateBookOperator)
+}
+
+// ParseResourceFilters build a filterchain for the resources of the type from a string. If anything goes wrong, it returns an ErrInvalidFilter.
+func ParseResourceFilters(kind ResourceType, filters string) (chain *FilterChain, err error) {
+	return parseResourceFilters(filters, kind.Identifier(), kind.DateField(), kind.ValidateField, kind.ValidateValue, kind.ValidateOperator)
+}
+
+// parseResourceFilters build a filterchain from a string. If the identifier is compared for equality, the other filters are ignored.
+func parseResourceFilters(filters string, identifier string, dateField string, validateField fieldValidatorFunc, validateValue valueValidatorFunc, validateOperator operatorValidatorFunc) (chain *FilterChain, err error) {
+	filterChain, err := parseFilters(filters, dateField, validateField, validateValue, validateOperator)
 
 	if err != nil {
 		return nil, err
@@ -128,102 +412,181 @@ func ParseFilters(filters string, validateField fieldValidatorFunc, validateValu
 		return nil, fmt.Errorf("no filters")
 	}
 
-	var isbnFilter *Filter
-	converter, exists := filterChain.Get("Isbn")
+	converter, exists := filterChain.Get(identifier)
 
-	// Use only isbn to retrieve the book if specified
-	if exists {
-		isbnFilter = converter.(*Filter)
+	// Use only the identifier to retrieve the resource if specified, while a list of identifiers is combined with the other filters
+	if idFilter, ok := converter.(*Filter); exists && ok {
 		singleChain := newFilterChain()
 
-		if isbnFilter.Operation != Equals {
-			return nil, fmt.Errorf(ErrInvalidFilter+" not admitted for isbn filter", isbnFilter.Operation)
+		if idFilter.Operation != Equals {
+			return nil, fmt.Errorf(ErrInvalidFilter+" not admitted for %v filter", idFilter.Operation, strcase.ToSnake(identifier))
 		}
-		singleChain.add(isbnFilter)
+		singleChain.add(idFilter)
 		return singleChain, nil
 	}
 
 	return filterChain, nil
 }
 
-// ParseFilters build a filterchain from a string. If anything goes wrong, it returns an ErrInvalidFilter. It requires a fieldvalidator and a valuevalidator to perform type checking on the struct field.
-func parseFilters(filters string, validateField fieldValidatorFunc, validateValue valueValidatorFunc) (chain *FilterChain, err error) {
-	chain = newFilterChain()
+// parseFilters build a filterchain from a string. If anything goes wrong, it returns an ErrInvalidFilter. The `dates` range filter applies to dateField.
+// The filters joined by `and` at the top of the expression make up the chain, while `or`, `not` and parentheses nest the filters in a single element of it.
+func parseFilters(filters string, dateField string, validateField fieldValidatorFunc, validateValue valueValidatorFunc, validateOperator operatorValidatorFunc) (chain *FilterChain, err error) {
+	root, err := parseExpression(filters, func(field string, operator string, value string) (SQLConverter, error) {
+		return parseComparison(field, operator, value, dateField, validateField, validateValue, validateOperator)
+	}, func(field string, operator string) bool {
+		return isComparison(field, operator, validateField, validateOperator)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Split all the single filters
-	filtersRaw := strings.Split(filters, "_"+And.String()+"_")
+	chain = newFilterChain()
+	if and, ok := root.(*AndFilter); ok {
+		for _, f := range and.Filters {
+			chain.add(f)
+		}
+		return chain, nil
+	}
+	return chain.add(root), nil
+}
 
-	for _, filter := range filtersRaw {
-		// Split field, operator and value
-		parts := strings.Split(filter, "_")
+// parseComparison build the filter comparing a field with a value. If anything goes wrong, it returns an ErrInvalidFilter.
+func parseComparison(field string, operator string, value string, dateField string, validateField fieldValidatorFunc, validateValue valueValidatorFunc, validateOperator operatorValidatorFunc) (SQLConverter, error) {
+	// Transform field string to UpperCamelCase and remove spaces
+	field = strcase.ToCamel(field)
 
-		if len(parts)%3 != 0 {
-			return nil, fmt.Errorf(ErrInvalidFilter, "wrong number of filter parts")
+	// date filter should be handle as a special case
+	if field == "Dates" {
+		converter, err := parseDateRange(dateField, value)
+		if err != nil {
+			return nil, fmt.Errorf(ErrInvalidFilter, err)
 		}
+		return converter, nil
+	}
 
-		// Transform field string to UpperCamelCase and remove spaces
-		field := strcase.ToCamel(parts[0])
-		operator := parts[1]
-		value := parts[2]
+	if !validateField(field) {
+		return nil, fmt.Errorf(ErrInvalidFilter+" does not exists", field)
+	}
+
+	f := &Filter{}
+	f.Field = field
 
-		var converter SQLConverter
+	op, ok := parseOperator(operator)
+	if !ok {
+		return nil, fmt.Errorf(ErrInvalidFilter+" does not exists", operator)
+	}
+	f.Operation = op
+
+	if !validateOperator(field, f.Operation) {
+		return nil, fmt.Errorf(ErrInvalidFilter+" not admitted for %v field", f.Operation, strcase.ToSnake(field))
+	}
 
-		// date filter should be handle as a special case
-		if field == "Dates" {
-			converter, err = parseDateRange(value)
+	// the in operator takes a list of values, each one checked on its own
+	if f.Operation == In {
+		in := &InFilter{Field: field}
+		for _, v := range strings.Split(value, inSeparator) {
+			v, err := parseValue(field, v, validateValue)
 			if err != nil {
-				return nil, fmt.Errorf(ErrInvalidFilter, err)
+				return nil, err
 			}
-			chain.add(converter)
-			continue
+			in.Values = append(in.Values, v)
 		}
+		return in, nil
+	}
 
-		if !validateField(field) {
-			return nil, fmt.Errorf(ErrInvalidFilter+" does not exists", field)
-		}
+	var err error
+	f.Value, err = parseValue(field, value, validateValue)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
 
-		f := &Filter{}
-		f.Field = field
+// parseOperator returns the comparison operator named by the string. The boolean is false if there is none.
+func parseOperator(operator string) (Operator, bool) {
+	switch op := Operator(operator); op {
+	case Equals, NotEqual, LessThan, LessOrEqual, GreaterThan, GreaterOrEqual, Contains, StartsWith, EndsWith, In:
+		return op, true
+	default:
+		return "", false
+	}
+}
 
-		switch operator {
-		case Equals.String():
-			f.Operation = Equals
-			break
-		case NotEqual.String():
-			f.Operation = NotEqual
-			break
-		default:
-			return nil, fmt.Errorf(ErrInvalidFilter+" does not exists", operator)
-		}
+// isComparison reports whether parseComparison accepts the field and the operator, whatever the value
+func isComparison(field string, operator string, validateField fieldValidatorFunc, validateOperator operatorValidatorFunc) bool {
+	field = strcase.ToCamel(field)
+	if field == "Dates" {
+		return true
+	}
 
-		// replace all '-' if the value is not a date
-		if isDate := regexp.MustCompile(`^[0-9|-]+$`).MatchString; !isDate(value) {
-			value = strings.ReplaceAll(value, "-", " ")
-		}
+	op, ok := parseOperator(operator)
+	return ok && validateField(field) && validateOperator(field, op)
+}
+
+// FilterPattern is the regular expression matching the filter strings, whose values are encoded with EncodeFilterValue.
+// It admits raw underscores and parentheses, as the grammar needs them: the parser tells a raw `_and_` or `_or_` inside a value from the operator.
+const FilterPattern = `[0-9A-Za-z_|()%.~-]*`
+
+// isDateValue reports whether a filter value is made of digits and hyphens only, like a date. Its hyphens are literal,
+// while the hyphens of any other value stand for spaces.
+var isDateValue = regexp.MustCompile(`^[0-9-]+$`).MatchString
+
+// EncodeFilterValue encodes a value so that the filters read it back unchanged, e.g. "Jean-Paul Sartre" as `Jean%2DPaul%20Sartre`.
+// Letters, digits, '.' and '~' are kept as they are, and so are the hyphens of a date, while any other byte is percent-encoded.
+func EncodeFilterValue(value string) string {
+	date := isDateValue(value)
 
-		if !validateValue(field, value) {
-			return nil, fmt.Errorf(ErrInvalidFilter+" has a mismatching type", field)
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '~', c == '-' && date:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
 		}
+	}
+	return b.String()
+}
+
+// decodeValue reverts EncodeFilterValue. The hyphens of a value that is not a date are replaced by spaces first, as the filters written by hand expect.
+func decodeValue(value string) (string, error) {
+	if !isDateValue(value) {
+		value = strings.ReplaceAll(value, "-", " ")
+	}
+	return url.PathUnescape(value)
+}
 
-		f.Value = value
-		chain.add(f)
+// parseValue converts a filter value of the field to its actual value and checks its type
+func parseValue(field string, value string, validateValue valueValidatorFunc) (string, error) {
+	value, err := decodeValue(value)
+	if err != nil {
+		return "", fmt.Errorf(ErrInvalidFilter+" has a malformed value", field)
 	}
 
-	return chain, nil
+	if !validateValue(field, value) {
+		return "", fmt.Errorf(ErrInvalidFilter+" has a mismatching type", field)
+	}
+	return value, nil
 }
 
-func parseDateRange(dateRange string) (*DateRangeFilter, error) {
+func parseDateRange(field string, dateRange string) (*DateRangeFilter, error) {
 	parts := strings.Split(dateRange, "-to-")
 
 	if len(parts) != 2 {
 		return nil, fmt.Errorf("invalid date range")
 	}
 
-	startDate := parts[0]
-	endDate := parts[1]
+	startDate, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid date range: %v", err)
+	}
+	endDate, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid date range: %v", err)
+	}
 
 	return &DateRangeFilter{
-		Field:     "published_date",
+		Field:     field,
 		StartDate: startDate,
 		EndDate:   endDate,
 	}, nil
@@ -3,6 +3,7 @@ package apis
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -66,6 +67,221 @@ func TestParseFilters(t *testing.T) {
 				require.Equal(t, *desired, *actual)
 			},
 		},
+		{
+			description: "test greater than uint8",
+			input:       "edition_gt_2",
+			desired: newFilterChain().add(&Filter{
+				Field:     "Edition",
+				Operation: GreaterThan,
+				Value:     "2",
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test ordering operators concat",
+			input:       "published-date_ge_1800-01-01_and_published-date_lt_1900-01-01_and_edition_le_3",
+			desired: newFilterChain().add(&Filter{
+				Field:     "PublishedDate",
+				Operation: GreaterOrEqual,
+				Value:     "1800-01-01",
+			}).add(&Filter{
+				Field:     "PublishedDate",
+				Operation: LessThan,
+				Value:     "1900-01-01",
+			}).add(&Filter{
+				Field:     "Edition",
+				Operation: LessOrEqual,
+				Value:     "3",
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test ordering operator on a string field",
+			input:       "title_lt_pippo",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter+" not admitted for title field", LessThan.String()))
+			},
+		},
+		{
+			description: "test ordering operator with an invalid value",
+			input:       "published-date_gt_1900",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter, "PublishedDate has a mismatching type"))
+			},
+		},
+		{
+			description: "test pattern operators",
+			input:       "title_contains_romeo-and_and_author_startswith_william_and_genre_endswith_ma",
+			desired: newFilterChain().add(&Filter{
+				Field:     "Title",
+				Operation: Contains,
+				Value:     "romeo and",
+			}).add(&Filter{
+				Field:     "Author",
+				Operation: StartsWith,
+				Value:     "william",
+			}).add(&Filter{
+				Field:     "Genre",
+				Operation: EndsWith,
+				Value:     "ma",
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test pattern operator on a numeric field",
+			input:       "edition_contains_2",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter+" not admitted for edition field", Contains.String()))
+			},
+		},
+		{
+			description: "test in operator",
+			input:       "genre_in_drama|science-fiction_and_edition_in_1|2",
+			desired: newFilterChain().add(&InFilter{
+				Field:  "Genre",
+				Values: []string{"drama", "science fiction"},
+			}).add(&InFilter{
+				Field:  "Edition",
+				Values: []string{"1", "2"},
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test in operator validates every value",
+			input:       "edition_in_1|two",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter+" has a mismatching type", "Edition"))
+			},
+		},
+		{
+			description: "test or binds looser than and",
+			input:       "genre_eq_drama_and_edition_gt_2_or_author_eq_dante",
+			desired: newFilterChain().add(&OrFilter{Filters: []SQLConverter{
+				&AndFilter{Filters: []SQLConverter{
+					&Filter{Field: "Genre", Operation: Equals, Value: "drama"},
+					&Filter{Field: "Edition", Operation: GreaterThan, Value: "2"},
+				}},
+				&Filter{Field: "Author", Operation: Equals, Value: "dante"},
+			}}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test parentheses and not",
+			input:       "not_(genre_eq_drama_or_genre_eq_comedy)_and_(edition_eq_1)",
+			desired: newFilterChain().add(&NotFilter{Filter: &OrFilter{Filters: []SQLConverter{
+				&Filter{Field: "Genre", Operation: Equals, Value: "drama"},
+				&Filter{Field: "Genre", Operation: Equals, Value: "comedy"},
+			}}}).add(&Filter{Field: "Edition", Operation: Equals, Value: "1"}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test value with underscores and keywords",
+			input:       "title_eq_or_not_a_b_and_author_eq_x",
+			desired: newFilterChain().add(&Filter{
+				Field:     "Title",
+				Operation: Equals,
+				Value:     "or_not_a_b",
+			}).add(&Filter{
+				Field:     "Author",
+				Operation: Equals,
+				Value:     "x",
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test raw and inside a value",
+			input:       "title_eq_romeo_and_juliet",
+			desired: newFilterChain().add(&Filter{
+				Field:     "Title",
+				Operation: Equals,
+				Value:     "romeo_and_juliet",
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test raw or inside a value followed by a comparison",
+			input:       "title_eq_war_or_peace_or_not_genre_eq_drama",
+			desired: newFilterChain().add(&OrFilter{Filters: []SQLConverter{
+				&Filter{Field: "Title", Operation: Equals, Value: "war_or_peace"},
+				&NotFilter{Filter: &Filter{Field: "Genre", Operation: Equals, Value: "drama"}},
+			}}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test encoded and inside a value",
+			input:       "title_eq_romeo%5Fand%5Fjuliet_or_title_eq_" + EncodeFilterValue("war_or_peace"),
+			desired: newFilterChain().add(&OrFilter{Filters: []SQLConverter{
+				&Filter{Field: "Title", Operation: Equals, Value: "romeo_and_juliet"},
+				&Filter{Field: "Title", Operation: Equals, Value: "war_or_peace"},
+			}}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
+		{
+			description: "test missing closing parenthesis",
+			input:       "(genre_eq_drama_or_genre_eq_comedy",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter, "missing closing parenthesis"))
+			},
+		},
+		{
+			description: "test unexpected closing parenthesis",
+			input:       "genre_eq_drama)_and_edition_eq_1",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter, `unexpected ")"`))
+			},
+		},
+		{
+			description: "test missing value",
+			input:       "genre_eq_drama_or_edition_eq",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter, "wrong number of filter parts"))
+			},
+		},
+		{
+			description: "test validation within an or",
+			input:       "genre_eq_drama_or_edition_eq_first",
+			desired:     newFilterChain(),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter, "Edition has a mismatching type"))
+			},
+		},
 		{
 			description: "test unknown operator",
 			input:       "title_invalidop_William-Shakespeare",
@@ -134,6 +350,22 @@ func TestParseFilters(t *testing.T) {
 				require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter+" not admitted for isbn filter", NotEqual.String()))
 			},
 		},
+		{
+			description: "isbn in a list keeps the other filters",
+			input:       "genre_eq_drama_and_isbn_in_1234|5678",
+			desired: newFilterChain().add(&Filter{
+				Field:     "Genre",
+				Operation: Equals,
+				Value:     "drama",
+			}).add(&InFilter{
+				Field:  "Isbn",
+				Values: []string{"1234", "5678"},
+			}),
+			assert: func(desired, actual *FilterChain, err error) {
+				require.Nil(t, err)
+				require.Equal(t, *desired, *actual)
+			},
+		},
 	}
 
 	for _, tt := range testcases {
@@ -150,7 +382,7 @@ func TestSQL(t *testing.T) {
 		description    string
 		input          *FilterChain
 		desiredPrepare string
-		desiredValues  []string
+		desiredValues  []interface{}
 	}{
 		{
 			description: "test simple SQL",
@@ -160,7 +392,7 @@ func TestSQL(t *testing.T) {
 				Value:     "William Shakespeare",
 			}),
 			desiredPrepare: "title = ?",
-			desiredValues:  []string{"'William Shakespeare'"},
+			desiredValues:  []interface{}{"William Shakespeare"},
 		},
 		{
 			description: "test string and int",
@@ -176,7 +408,7 @@ func TestSQL(t *testing.T) {
 				},
 			),
 			desiredPrepare: "title = ? AND edition <> ?",
-			desiredValues:  []string{"'William Shakespeare'", "'2'"},
+			desiredValues:  []interface{}{"William Shakespeare", "2"},
 		},
 		{
 			description: "test string, int and date",
@@ -198,7 +430,76 @@ func TestSQL(t *testing.T) {
 				},
 			),
 			desiredPrepare: "title = ? AND edition <> ? AND published_date <> ?",
-			desiredValues:  []string{"'William Shakespeare'", "'2'", "'2020-01-01'"},
+			desiredValues:  []interface{}{"William Shakespeare", "2", "2020-01-01"},
+		},
+		{
+			description: "test ordering operators",
+			input: newFilterChain().add(&Filter{
+				Field:     "Edition",
+				Operation: GreaterThan,
+				Value:     "2",
+			}).add(&Filter{
+				Field:     "Edition",
+				Operation: LessOrEqual,
+				Value:     "5",
+			}).add(&Filter{
+				Field:     "PublishedDate",
+				Operation: LessThan,
+				Value:     "1900-01-01",
+			}).add(&Filter{
+				Field:     "PublishedDate",
+				Operation: GreaterOrEqual,
+				Value:     "1800-01-01",
+			}),
+			desiredPrepare: "edition > ? AND edition <= ? AND published_date < ? AND published_date >= ?",
+			desiredValues:  []interface{}{"2", "5", "1900-01-01", "1800-01-01"},
+		},
+		{
+			description: "test pattern operators escape the wildcards",
+			input: newFilterChain().add(&Filter{
+				Field:     "Title",
+				Operation: Contains,
+				Value:     "100%",
+			}).add(&Filter{
+				Field:     "Author",
+				Operation: StartsWith,
+				Value:     "a_b",
+			}).add(&Filter{
+				Field:     "Genre",
+				Operation: EndsWith,
+				Value:     "wow!",
+			}),
+			desiredPrepare: "title LIKE ? ESCAPE '!' AND author LIKE ? ESCAPE '!' AND genre LIKE ? ESCAPE '!'",
+			desiredValues:  []interface{}{"%100!%%", "a!_b%", "%wow!!"},
+		},
+		{
+			description: "test in operator",
+			input: newFilterChain().add(&InFilter{
+				Field:  "Genre",
+				Values: []string{"drama", "comedy", "tragedy"},
+			}).add(&Filter{
+				Field:     "Edition",
+				Operation: Equals,
+				Value:     "1",
+			}),
+			desiredPrepare: "genre IN (?, ?, ?) AND edition = ?",
+			desiredValues:  []interface{}{"drama", "comedy", "tragedy", "1"},
+		},
+		{
+			description: "test nested expressions",
+			input: newFilterChain().add(&OrFilter{Filters: []SQLConverter{
+				&AndFilter{Filters: []SQLConverter{
+					&Filter{Field: "Genre", Operation: Equals, Value: "drama"},
+					&NotFilter{Filter: &Filter{Field: "Edition", Operation: Equals, Value: "1"}},
+				}},
+				&Filter{Field: "Author", Operation: Equals, Value: "dante"},
+			}}).add(&Filter{
+				Field:     "Title",
+				Operation: NotEqual,
+				Value:     "inferno",
+			}),
+			desiredPrepare: "((genre = ? AND NOT (edition = ?)) OR author = ?) AND title <> ?",
+			desiredValues:  []interface{}{"drama", "1", "dante", "inferno"},
 		},
 	}
 
@@ -210,3 +511,344 @@ func TestSQL(t *testing.T) {
 		})
 	}
 }
+
+func TestDialectSQL(t *testing.T) {
+	newChain := func(titleOperation Operator) *FilterChain {
+		return newFilterChain().add(&Filter{
+			Field:     "Title",
+			Operation: titleOperation,
+			Value:     "William Shakespeare",
+		}).add(&DateRangeFilter{
+			Field:     "published_date",
+			StartDate: "2000-01-01",
+			EndDate:   "2000-12-31",
+		}).add(&Filter{
+			Field:     "Edition",
+			Operation: NotEqual,
+			Value:     "2",
+		})
+	}
+
+	testcases := []struct {
+		description    string
+		dialect        Dialect
+		offset         int
+		pattern        bool
+		desiredPrepare string
+	}{
+		{
+			description:    "mysql",
+			dialect:        MySQL,
+			desiredPrepare: "title = ? AND published_date >= ? AND published_date <= ? AND edition <> ?",
+		},
+		{
+			description:    "sqlite",
+			dialect:        SQLite,
+			desiredPrepare: "title = ? AND published_date >= ? AND published_date <= ? AND edition <> ?",
+		},
+		{
+			description:    "postgres",
+			dialect:        Postgres,
+			desiredPrepare: "title = $1 AND published_date >= $2 AND published_date <= $3 AND edition <> $4",
+		},
+		{
+			description:    "postgres pattern ignoring case",
+			dialect:        Postgres,
+			pattern:        true,
+			desiredPrepare: "title ILIKE $1 ESCAPE '!' AND published_date >= $2 AND published_date <= $3 AND edition <> $4",
+		},
+		{
+			description:    "postgres with offset",
+			dialect:        Postgres,
+			offset:         2,
+			desiredPrepare: "title = $3 AND published_date >= $4 AND published_date <= $5 AND edition <> $6",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.description, func(t *testing.T) {
+			chain := newChain(Equals)
+			desiredTitle := "William Shakespeare"
+			if tt.pattern {
+				chain = newChain(Contains)
+				desiredTitle = "%William Shakespeare%"
+			}
+
+			actualPrepare, actualQueryValues := chain.DialectSQLStatement(tt.dialect, tt.offset)
+			require.Equal(t, tt.desiredPrepare, actualPrepare)
+			require.Equal(t, []interface{}{desiredTitle, "2000-01-01", "2000-12-31", "2"}, actualQueryValues)
+		})
+	}
+
+	in := &InFilter{Field: "Genre", Values: []string{"drama", "comedy"}}
+	actualPrepare, actualQueryValues := in.DialectSQL(Postgres, 2)
+	require.Equal(t, "genre IN ($3, $4)", actualPrepare)
+	require.Equal(t, []interface{}{"drama", "comedy"}, actualQueryValues)
+
+	or := &OrFilter{Filters: []SQLConverter{in, &NotFilter{Filter: &Filter{Field: "Edition", Operation: Equals, Value: "1"}}}}
+	actualPrepare, actualQueryValues = or.DialectSQL(Postgres, 0)
+	require.Equal(t, "(genre IN ($1, $2) OR NOT (edition = $3))", actualPrepare)
+	require.Equal(t, []interface{}{"drama", "comedy", "1"}, actualQueryValues)
+}
+
+func TestMatch(t *testing.T) {
+	book := Book{
+		Title:         "Romeo and Juliet",
+		Author:        "William Shakespeare",
+		Isbn:          "1234567890987",
+		PublishedDate: Date(time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)),
+		Edition:       2,
+		Genre:         "Drama",
+	}
+
+	testcases := []struct {
+		description string
+		input       string
+		desired     bool
+	}{
+		{
+			description: "equal ignores case",
+			input:       "author_eq_william-shakespeare",
+			desired:     true,
+		},
+		{
+			description: "not equal",
+			input:       "title_ne_romeo-and-juliet",
+			desired:     false,
+		},
+		{
+			description: "uint8 value",
+			input:       "edition_eq_2",
+			desired:     true,
+		},
+		{
+			description: "date value",
+			input:       "published-date_ne_2000-01-02",
+			desired:     false,
+		},
+		{
+			description: "date range",
+			input:       "dates_eq_1999-01-01-to-2000-01-02",
+			desired:     true,
+		},
+		{
+			description: "date range excluding the book",
+			input:       "dates_eq_2000-01-03-to-2001-01-01",
+			desired:     false,
+		},
+		{
+			description: "greater than",
+			input:       "edition_gt_1",
+			desired:     true,
+		},
+		{
+			description: "greater than the same value",
+			input:       "edition_gt_2",
+			desired:     false,
+		},
+		{
+			description: "greater or equal",
+			input:       "edition_ge_2",
+			desired:     true,
+		},
+		{
+			description: "published before a date",
+			input:       "published-date_lt_2000-01-02",
+			desired:     false,
+		},
+		{
+			description: "published until a date",
+			input:       "published-date_le_2000-01-02",
+			desired:     true,
+		},
+		{
+			description: "contains ignores case",
+			input:       "title_contains_JULIET",
+			desired:     true,
+		},
+		{
+			description: "starts with",
+			input:       "author_startswith_william-s",
+			desired:     true,
+		},
+		{
+			description: "ends with",
+			input:       "author_endswith_william",
+			desired:     false,
+		},
+		{
+			description: "in any of the values",
+			input:       "genre_in_comedy|drama",
+			desired:     true,
+		},
+		{
+			description: "in none of the values",
+			input:       "edition_in_1|3",
+			desired:     false,
+		},
+		{
+			description: "either filter",
+			input:       "genre_eq_comedy_or_edition_eq_2",
+			desired:     true,
+		},
+		{
+			description: "neither filter",
+			input:       "genre_eq_comedy_or_edition_eq_1",
+			desired:     false,
+		},
+		{
+			description: "negated group",
+			input:       "not_(genre_eq_comedy_or_edition_eq_1)",
+			desired:     true,
+		},
+		{
+			description: "concat filters",
+			input:       "genre_eq_drama_and_edition_ne_1",
+			desired:     true,
+		},
+		{
+			description: "concat filters with a mismatch",
+			input:       "genre_eq_drama_and_edition_ne_2",
+			desired:     false,
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.description, func(t *testing.T) {
+			chain, err := ParseFilters(tt.input, ValidateBookField, ValidateBookValue)
+			require.Nil(t, err)
+
+			require.Equal(t, tt.desired, chain.Match(book))
+			require.Equal(t, tt.desired, chain.Match(&book))
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	testcases := []struct {
+		description string
+		inputs      []string
+		desired     string
+	}{
+		{
+			description: "single filter",
+			inputs:      []string{"published-date_ne_2000-01-02"},
+			desired:     "published-date_ne_2000-01-02",
+		},
+		{
+			description: "filters in any order",
+			inputs:      []string{"genre_eq_drama_and_edition_ne_1", "edition_ne_1_and_genre_eq_drama"},
+			desired:     "edition_ne_1_and_genre_eq_drama",
+		},
+		{
+			description: "date range",
+			inputs:      []string{"dates_eq_1999-01-01-to-2000-01-02_and_author_eq_william-shakespeare"},
+			desired:     "author_eq_william%20shakespeare_and_dates_eq_1999-01-01-to-2000-01-02",
+		},
+		{
+			description: "in operator",
+			inputs:      []string{"genre_in_drama|comedy"},
+			desired:     "genre_in_drama|comedy",
+		},
+		{
+			description: "nested expressions",
+			inputs:      []string{"edition_eq_1_and_(genre_eq_drama_or_not_genre_eq_comedy)", "(genre_eq_drama_or_not_(genre_eq_comedy))_and_edition_eq_1"},
+			desired:     "(genre_eq_drama_or_not_genre_eq_comedy)_and_edition_eq_1",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.description, func(t *testing.T) {
+			for _, input := range tt.inputs {
+				chain, err := ParseFilters(input, ValidateBookField, ValidateBookValue)
+				require.Nil(t, err)
+				require.Equal(t, tt.desired, chain.Canonical())
+			}
+
+			// the canonical form parses to the same filters
+			chain, err := ParseFilters(tt.desired, ValidateBookField, ValidateBookValue)
+			require.Nil(t, err)
+			require.Equal(t, tt.desired, chain.Canonical())
+		})
+	}
+}
+
+func TestEncodeFilterValue(t *testing.T) {
+	testcases := []struct {
+		description string
+		value       string
+		desired     string
+	}{
+		{
+			description: "hyphen and capitals",
+			value:       "Jean-Paul Sartre",
+			desired:     "Jean%2DPaul%20Sartre",
+		},
+		{
+			description: "apostrophe",
+			value:       "O'Brien",
+			desired:     "O%27Brien",
+		},
+		{
+			description: "underscore",
+			value:       "science_fiction",
+			desired:     "science%5Ffiction",
+		},
+		{
+			description: "keywords of the grammar",
+			value:       "Romeo and Juliet (or not)|1%",
+			desired:     "Romeo%20and%20Juliet%20%28or%20not%29%7C1%25",
+		},
+		{
+			description: "date",
+			value:       "2000-01-02",
+			desired:     "2000-01-02",
+		},
+		{
+			description: "non ascii",
+			value:       "Così è",
+			desired:     "Cos%C3%AC%20%C3%A8",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.description, func(t *testing.T) {
+			encoded := EncodeFilterValue(tt.value)
+			require.Equal(t, tt.desired, encoded)
+			require.Regexp(t, "^"+FilterPattern+"$", encoded)
+
+			// the encoded value reads back unchanged, alone or in a list
+			chain, err := ParseFilters("title_eq_"+encoded+"_and_genre_in_"+encoded+"|x", ValidateBookField, ValidateBookValue)
+			require.Nil(t, err)
+			require.Equal(t, *newFilterChain().add(&Filter{
+				Field:     "Title",
+				Operation: Equals,
+				Value:     tt.value,
+			}).add(&InFilter{
+				Field:  "Genre",
+				Values: []string{tt.value, "x"},
+			}), *chain)
+		})
+	}
+
+	_, err := ParseFilters("title_eq_50%_off", ValidateBookField, ValidateBookValue)
+	require.EqualError(t, err, fmt.Sprintf(ErrInvalidFilter+" has a malformed value", "Title"))
+}
+
+func TestParseResourceFilters(t *testing.T) {
+	chain, err := ParseResourceFilters(CollectionType, "name_in_classics|drama_and_creation-date_ge_2020-01-01")
+	require.Nil(t, err)
+	require.Equal(t, *newFilterChain().add(&InFilter{
+		Field:  "Name",
+		Values: []string{"classics", "drama"},
+	}).add(&Filter{
+		Field:     "CreationDate",
+		Operation: GreaterOrEqual,
+		Value:     "2020-01-01",
+	}), *chain)
+
+	// a single name selects the collection regardless of the other filters
+	chain, err = ParseResourceFilters(CollectionType, "name_eq_classics_and_creation-date_ge_2020-01-01")
+	require.Nil(t, err)
+	require.Equal(t, *newFilterChain().add(&Filter{Field: "Name", Operation: Equals, Value: "classics"}), *chain)
+}
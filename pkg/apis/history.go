@@ -0,0 +1,104 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	// PrincipalHeader is the request header naming who performs a change. It is recorded in the history of the changed resources.
+	PrincipalHeader = "X-Principal"
+	// MaxPrincipalLength is the maximum length of the principal recorded in the history
+	MaxPrincipalLength = 64
+)
+
+// HistoryOperation is the kind of change recorded by a history entry
+type HistoryOperation string
+
+const (
+	// HistoryCreate records the creation of a resource
+	HistoryCreate HistoryOperation = "create"
+	// HistoryUpdate records the update of a resource, including the changes of the members of a collection
+	HistoryUpdate HistoryOperation = "update"
+	// HistoryDelete records a resource moved to the trash
+	HistoryDelete HistoryOperation = "delete"
+	// HistoryRestore records a resource moved out of the trash
+	HistoryRestore HistoryOperation = "restore"
+	// HistoryPurge records a resource permanently removed from the trash
+	HistoryPurge HistoryOperation = "purge"
+)
+
+// HistoryEntry records a change of a book or a collection.
+// Before and After are the JSON representations of the resource around the change, null when the resource did not exist.
+type HistoryEntry struct {
+	Resource  ResourceType     `json:"resource"`
+	ID        string           `json:"id"`
+	Operation HistoryOperation `json:"operation"`
+	Principal string           `json:"principal"`
+	ChangedAt time.Time        `json:"changed_at"`
+	Before    json.RawMessage  `json:"before"`
+	After     json.RawMessage  `json:"after"`
+	Changes   []FieldChange    `json:"changes"`
+}
+
+// FieldChange is a field whose value differs between the two sides of a history entry. A missing field has a nil value.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff returns the fields that differ between the before and after JSON objects, ordered by name. A null or empty document has no fields.
+func Diff(before, after json.RawMessage) ([]FieldChange, error) {
+	previous, err := decodeFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := decodeFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range previous {
+		fields = append(fields, field)
+	}
+	for field := range current {
+		if _, ok := previous[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if !reflect.DeepEqual(previous[field], current[field]) {
+			changes = append(changes, FieldChange{Field: field, Before: previous[field], After: current[field]})
+		}
+	}
+	return changes, nil
+}
+
+// decodeFields decodes a JSON object keeping the numbers as they are written
+func decodeFields(doc json.RawMessage) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if len(doc) == 0 {
+		return fields, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	// null decodes into a nil map
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	return fields, nil
+}
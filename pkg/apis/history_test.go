@@ -0,0 +1,30 @@
+package apis
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff(nil, json.RawMessage(`{"title":"Hamlet","edition":2}`))
+	require.Nil(t, err)
+	require.Equal(t, []FieldChange{
+		{Field: "edition", After: json.Number("2")},
+		{Field: "title", After: "Hamlet"},
+	}, changes)
+
+	changes, err = Diff(json.RawMessage(`{"title":"Hamlet","books":["1"]}`), json.RawMessage(`{"title":"Hamlet","books":["1","2"]}`))
+	require.Nil(t, err)
+	require.Equal(t, []FieldChange{
+		{Field: "books", Before: []interface{}{"1"}, After: []interface{}{"1", "2"}},
+	}, changes)
+
+	changes, err = Diff(json.RawMessage(`{"title":"Hamlet"}`), json.RawMessage(`null`))
+	require.Nil(t, err)
+	require.Equal(t, []FieldChange{{Field: "title", Before: "Hamlet"}}, changes)
+
+	_, err = Diff(json.RawMessage(`[]`), nil)
+	require.NotNil(t, err)
+}
@@ -5,11 +5,53 @@ import (
 	"net/http"
 )
 
+// ErrorCode is the machine-readable identifier of an error
+type ErrorCode string
+
+const (
+	CodeBadRequest           ErrorCode = "bad_request"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeConflict             ErrorCode = "conflict"
+	CodePreconditionFailed   ErrorCode = "precondition_failed"
+	CodePreconditionRequired ErrorCode = "precondition_required"
+	CodeValidation           ErrorCode = "validation_failed"
+	CodeUnavailable          ErrorCode = "unavailable"
+	CodeTimeout              ErrorCode = "timeout"
+	CodeInternal             ErrorCode = "internal"
+)
+
+// errorCodeFor returns the ErrorCode corresponding to an HTTP status code
+func errorCodeFor(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return CodePreconditionRequired
+	case http.StatusUnprocessableEntity:
+		return CodeValidation
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	case http.StatusInternalServerError:
+		return CodeInternal
+	default:
+		return CodeInternal
+	}
+}
+
 // Message is the object return from every request
 type Message struct {
-	Status   string `json:"status"`
-	Code     int    `json:"code"`
-	Metadata string `json:"metadata"`
+	Status   string    `json:"status"`
+	Code     int       `json:"code"`
+	Error    ErrorCode `json:"error,omitempty"`
+	Metadata string    `json:"metadata"`
 }
 
 // JSON return the JSON representation of the Message
@@ -20,11 +62,12 @@ func (m *Message) JSON() string {
 	return res
 }
 
-// NewError creates a new error Message
+// NewError creates a new error Message. The machine-readable error is derived from the HTTP status code.
 func NewError(code int, error error) *Message {
 	return &Message{
 		Status:   "error",
 		Code:     code,
+		Error:    errorCodeFor(code),
 		Metadata: error.Error(),
 	}
 }
@@ -0,0 +1,264 @@
+package apis
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+)
+
+// SortField is a field used to order a listing
+type SortField struct {
+	// Field is the resource field name in UpperCamelCase
+	Field      string
+	Descending bool
+}
+
+// Deleted selects the resources of a listing depending on whether they are in the trash
+type Deleted int
+
+const (
+	// ExcludeDeleted lists the resources that are not in the trash
+	ExcludeDeleted Deleted = iota
+	// IncludeDeleted lists the resources whether they are in the trash or not
+	IncludeDeleted
+	// OnlyDeleted lists the resources in the trash
+	OnlyDeleted
+)
+
+// Match reports whether a resource deleted at the given time, nil if it is not in the trash, is selected
+func (d Deleted) Match(deletedAt *time.Time) bool {
+	switch d {
+	case IncludeDeleted:
+		return true
+	case OnlyDeleted:
+		return deletedAt != nil
+	default:
+		return deletedAt == nil
+	}
+}
+
+// ListOptions controls the pagination and the ordering of a listing. The zero value returns every resource not in the trash ordered by identifier.
+type ListOptions struct {
+	// Limit is the maximum number of resources in a page. Zero means no limit.
+	Limit int
+	// Offset is the number of resources to skip
+	Offset int
+	// Cursor is the identifier of the last resource of the previous page. Only the resources with a greater identifier are returned.
+	Cursor string
+	// Sort lists the fields used to order the resources. The identifier is always used as last criterion.
+	Sort []SortField
+	// Deleted selects the resources depending on whether they are in the trash
+	Deleted Deleted
+	// AsOf, unless zero, lists the resources as they were at that time, as reconstructed from their history
+	AsOf time.Time
+}
+
+// Page holds the pagination details of a listing
+type Page struct {
+	// Total is the number of resources matching the filters across all pages
+	Total int64 `json:"total"`
+	// NextCursor is the cursor to request the following page. It is empty on the last page or if the ordering does not allow keyset pagination.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BookPage is a page of a book listing
+type BookPage struct {
+	Items []Book `json:"items"`
+	Page
+}
+
+// CollectionPage is a page of a collection listing
+type CollectionPage struct {
+	Items []Collection `json:"items"`
+	Page
+}
+
+// KeysetOrdered reports whether the resources are ordered by identifier only, which is required by cursor pagination
+func (o ListOptions) KeysetOrdered(identifier string) bool {
+	return len(o.Sort) == 0 || (len(o.Sort) == 1 && o.Sort[0].Field == identifier && !o.Sort[0].Descending)
+}
+
+// ParseListOptions reads the `limit`, `offset`, `cursor`, `sort`, `include_deleted`, `only_deleted` and `as_of` query parameters. identifier is the field used by cursor pagination,
+// while validateField checks the sort fields, e.g. ValidateBookSortField.
+// The sort parameter is a comma separated list of fields in snake case, a leading `-` reverses the order (e.g. `-published_date,title`).
+// The as_of parameter is either an RFC 3339 timestamp or a date, which stands for the end of that day in UTC.
+func ParseListOptions(values url.Values, identifier string, validateField fieldValidatorFunc) (opts ListOptions, err error) {
+	if limit := values.Get("limit"); limit != "" {
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.Limit < 0 {
+			return ListOptions{}, fmt.Errorf("invalid limit: %v", limit)
+		}
+	}
+
+	if offset := values.Get("offset"); offset != "" {
+		opts.Offset, err = strconv.Atoi(offset)
+		if err != nil || opts.Offset < 0 {
+			return ListOptions{}, fmt.Errorf("invalid offset: %v", offset)
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		for _, raw := range strings.Split(sort, ",") {
+			field := SortField{}
+			if strings.HasPrefix(raw, "-") {
+				field.Descending = true
+				raw = raw[1:]
+			}
+
+			field.Field = strcase.ToCamel(raw)
+			if raw == "" || !validateField(field.Field) {
+				return ListOptions{}, fmt.Errorf("invalid sort field: %v", raw)
+			}
+			opts.Sort = append(opts.Sort, field)
+		}
+	}
+
+	for param, deleted := range map[string]Deleted{"include_deleted": IncludeDeleted, "only_deleted": OnlyDeleted} {
+		raw := values.Get(param)
+		if raw == "" {
+			continue
+		}
+
+		set, err := strconv.ParseBool(raw)
+		if err != nil {
+			return ListOptions{}, fmt.Errorf("invalid %v: %v", param, raw)
+		}
+		if !set {
+			continue
+		}
+		if opts.Deleted != ExcludeDeleted {
+			return ListOptions{}, fmt.Errorf("include_deleted and only_deleted cannot be used together")
+		}
+		opts.Deleted = deleted
+	}
+
+	if asOf := values.Get("as_of"); asOf != "" {
+		opts.AsOf, err = parseAsOf(asOf)
+		if err != nil {
+			return ListOptions{}, fmt.Errorf("invalid as_of: %v", asOf)
+		}
+	}
+
+	opts.Cursor = values.Get("cursor")
+	if opts.Cursor != "" {
+		if opts.Offset != 0 {
+			return ListOptions{}, fmt.Errorf("cursor and offset cannot be used together")
+		}
+		if !opts.KeysetOrdered(identifier) {
+			return ListOptions{}, fmt.Errorf("cursor pagination requires sorting by %v", strcase.ToSnake(identifier))
+		}
+	}
+
+	return opts, nil
+}
+
+// parseAsOf parses an RFC 3339 timestamp or a date, returning the last instant of the day in UTC
+func parseAsOf(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t.UTC(), nil
+	}
+
+	day, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// Values encodes the options as query parameters
+func (o ListOptions) Values() url.Values {
+	values := url.Values{}
+
+	if o.Limit != 0 {
+		values.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset != 0 {
+		values.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Cursor != "" {
+		values.Set("cursor", o.Cursor)
+	}
+	if len(o.Sort) != 0 {
+		fields := make([]string, len(o.Sort))
+		for i, f := range o.Sort {
+			fields[i] = strcase.ToSnake(f.Field)
+			if f.Descending {
+				fields[i] = "-" + fields[i]
+			}
+		}
+		values.Set("sort", strings.Join(fields, ","))
+	}
+	switch o.Deleted {
+	case IncludeDeleted:
+		values.Set("include_deleted", "true")
+	case OnlyDeleted:
+		values.Set("only_deleted", "true")
+	}
+	if !o.AsOf.IsZero() {
+		values.Set("as_of", o.AsOf.UTC().Format(time.RFC3339Nano))
+	}
+
+	return values
+}
+
+// CompareResources compares two resources of the same type field by field according to the sort fields.
+// It returns -1, 0 or +1 like strings.Compare, strings are compared ignoring case.
+func CompareResources(a, b interface{}, sort []SortField) int {
+	va := reflect.Indirect(reflect.ValueOf(a))
+	vb := reflect.Indirect(reflect.ValueOf(b))
+
+	for _, f := range sort {
+		cmp := compareValues(va.FieldByName(f.Field), vb.FieldByName(f.Field))
+		if f.Descending {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// CompareIdentifiers compares two numeric identifiers, such as ISBNs, by value
+func CompareIdentifiers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareValues compares two field values of the same type
+func compareValues(a, b reflect.Value) int {
+	if !a.IsValid() || !b.IsValid() {
+		return 0
+	}
+
+	switch a.Type() {
+	case reflect.TypeOf(Date{}):
+		return strings.Compare(a.Interface().(Date).String(), b.Interface().(Date).String())
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	}
+	return 0
+}
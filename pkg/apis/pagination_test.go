@@ -0,0 +1,129 @@
+package apis
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListOptions(t *testing.T) {
+	testcases := []struct {
+		description string
+		input       string
+		desired     ListOptions
+		wantErr     bool
+	}{
+		{
+			description: "test empty",
+			input:       "",
+			desired:     ListOptions{},
+		},
+		{
+			description: "test limit and sort",
+			input:       "limit=10&offset=20&sort=-published_date,title",
+			desired: ListOptions{
+				Limit:  10,
+				Offset: 20,
+				Sort: []SortField{
+					{Field: "PublishedDate", Descending: true},
+					{Field: "Title"},
+				},
+			},
+		},
+		{
+			description: "test cursor",
+			input:       "limit=10&cursor=9780671722852&sort=isbn",
+			desired: ListOptions{
+				Limit:  10,
+				Cursor: "9780671722852",
+				Sort:   []SortField{{Field: "Isbn"}},
+			},
+		},
+		{
+			description: "test only deleted",
+			input:       "only_deleted=true&include_deleted=false",
+			desired:     ListOptions{Deleted: OnlyDeleted},
+		},
+		{
+			description: "test include and only deleted",
+			input:       "include_deleted=1&only_deleted=1",
+			wantErr:     true,
+		},
+		{
+			description: "test as of date",
+			input:       "as_of=2025-12-31",
+			desired:     ListOptions{AsOf: time.Date(2025, 12, 31, 23, 59, 59, 999999999, time.UTC)},
+		},
+		{
+			description: "test as of timestamp",
+			input:       "as_of=2025-12-31T10:00:00%2B02:00",
+			desired:     ListOptions{AsOf: time.Date(2025, 12, 31, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			description: "test invalid as of",
+			input:       "as_of=yesterday",
+			wantErr:     true,
+		},
+		{
+			description: "test negative limit",
+			input:       "limit=-1",
+			wantErr:     true,
+		},
+		{
+			description: "test unknown sort field",
+			input:       "sort=pages",
+			wantErr:     true,
+		},
+		{
+			description: "test sort by deletion time",
+			input:       "sort=-deleted_at",
+			wantErr:     true,
+		},
+		{
+			description: "test sort by version",
+			input:       "sort=-version",
+			desired:     ListOptions{Sort: []SortField{{Field: "Version", Descending: true}}},
+		},
+		{
+			description: "test cursor with offset",
+			input:       "cursor=1&offset=1",
+			wantErr:     true,
+		},
+		{
+			description: "test cursor with sort",
+			input:       "cursor=1&sort=title",
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.description, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.input)
+			require.Nil(t, err)
+
+			actual, err := ParseListOptions(values, "Isbn", ValidateBookSortField)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.desired, actual)
+
+			roundTrip, err := ParseListOptions(actual.Values(), "Isbn", ValidateBookSortField)
+			require.Nil(t, err)
+			require.Equal(t, actual, roundTrip)
+		})
+	}
+}
+
+func TestValidateSortField(t *testing.T) {
+	for _, kind := range []ResourceType{BookType, CollectionType} {
+		require.True(t, kind.ValidateSortField(kind.Identifier()))
+		require.True(t, kind.ValidateSortField("Version"))
+		require.False(t, kind.ValidateSortField("DeletedAt"), kind)
+	}
+	require.True(t, ValidateCollectionSortField("CreationDate"))
+	require.False(t, ValidateCollectionSortField("Books"))
+}
@@ -1,6 +1,7 @@
 package apis
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -31,6 +32,66 @@ func (r ResourceType) Plural() string {
 	return fmt.Sprintf("%vs", r)
 }
 
+// Identifier returns the name of the field identifying a resource of the type
+func (r ResourceType) Identifier() string {
+	switch r {
+	case CollectionType:
+		return "Name"
+	default:
+		return "Isbn"
+	}
+}
+
+// DateField returns the column filtered by the `dates` range filter
+func (r ResourceType) DateField() string {
+	switch r {
+	case CollectionType:
+		return "creation_date"
+	default:
+		return "published_date"
+	}
+}
+
+// ValidateField check if a field exists in the resources of the type
+func (r ResourceType) ValidateField(f string) bool {
+	switch r {
+	case CollectionType:
+		return ValidateCollectionField(f)
+	default:
+		return ValidateBookField(f)
+	}
+}
+
+// ValidateSortField check if the resources of the type can be ordered by a field
+func (r ResourceType) ValidateSortField(f string) bool {
+	switch r {
+	case CollectionType:
+		return ValidateCollectionSortField(f)
+	default:
+		return ValidateBookSortField(f)
+	}
+}
+
+// ValidateValue check if a value can be assigned to the field in the resources of the type
+func (r ResourceType) ValidateValue(f string, v string) bool {
+	switch r {
+	case CollectionType:
+		return ValidateCollectionValue(f, v)
+	default:
+		return ValidateBookValue(f, v)
+	}
+}
+
+// ValidateOperator check if the filter operator can be applied to the field in the resources of the type
+func (r ResourceType) ValidateOperator(f string, op Operator) bool {
+	switch r {
+	case CollectionType:
+		return ValidateCollectionOperator(f, op)
+	default:
+		return ValidateBookOperator(f, op)
+	}
+}
+
 // GetResource returns the corresponding resource type
 func GetResource(s string) ResourceType {
 	switch s {
@@ -43,15 +104,19 @@ func GetResource(s string) ResourceType {
 	}
 }
 
-// Book represents the book object
+// Book represents the book object. The `db` tags map the fields to the columns of the books table and must be the snake case of the field name, as used by the filters.
 type Book struct {
-	Title         string `json:"title"`
-	Author        string `json:"author"`
-	Isbn          string `json:"isbn"`
-	PublishedDate Date   `json:"published_date"`
-	Edition       uint8  `json:"edition"`
-	Description   string `json:"description"`
-	Genre         string `json:"genre"`
+	Title         string `json:"title" db:"title"`
+	Author        string `json:"author" db:"author"`
+	Isbn          string `json:"isbn" db:"isbn"`
+	PublishedDate Date   `json:"published_date" db:"published_date"`
+	Edition       uint8  `json:"edition" db:"edition"`
+	Description   string `json:"description" db:"description"`
+	Genre         string `json:"genre" db:"genre"`
+	// Version is incremented on every change of the book. It is returned as the ETag of the book and checked against the If-Match header of the requests.
+	Version uint64 `json:"version,omitempty" db:"version"`
+	// DeletedAt is the time the book was moved to the trash, nil if it was not deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 const dateLayout = "2006-01-02"
@@ -81,26 +146,149 @@ func (j Date) String() string {
 	return t.Format(dateLayout)
 }
 
+// Scan implements the sql.Scanner interface. Drivers return dates either as time.Time or as text starting with the `dateLayout` format
+func (j *Date) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j = Date(time.Time{})
+	case time.Time:
+		*j = Date(v)
+	case []byte:
+		return j.parse(string(v))
+	case string:
+		return j.parse(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface. Dates are stored according to the dateLayout format
+func (j Date) Value() (driver.Value, error) {
+	return j.String(), nil
+}
+
+func (j *Date) parse(s string) error {
+	if len(s) > len(dateLayout) {
+		s = s[:len(dateLayout)]
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return err
+	}
+	*j = Date(t)
+	return nil
+}
+
 // ValidateBookField check if a field exists in book struct
 func ValidateBookField(f string) (exists bool) {
-	b := Book{}
-	_, exists = reflect.TypeOf(b).FieldByName(f)
-	return
+	return validateField(reflect.TypeOf(Book{}), f)
+}
+
+// ValidateBookSortField check if the books can be ordered by a field
+func ValidateBookSortField(f string) bool {
+	return validateSortField(reflect.TypeOf(Book{}), f)
 }
 
 // ValidateBookValue check if a value can be assigned to the field in book struct
 func ValidateBookValue(f string, v string) (ok bool) {
-	b := Book{}
-	field, _ := reflect.TypeOf(b).FieldByName(f)
+	return validateValue(reflect.TypeOf(Book{}), f, v)
+}
+
+// ValidateBookOperator check if the filter operator can be applied to the field in book struct
+func ValidateBookOperator(f string, op Operator) (ok bool) {
+	return validateOperator(reflect.TypeOf(Book{}), f, op)
+}
+
+// Collection represents a set of books. Books holds the ISBNs of the members, which are stored in the collection_members table.
+type Collection struct {
+	Name         string   `json:"name" db:"name"`
+	Description  string   `json:"description" db:"description"`
+	CreationDate Date     `json:"creation_date" db:"creation_date"`
+	Books        []string `json:"books" db:"-"`
+	// Version is incremented on every change of the collection, including its members. It is returned as the ETag of the collection and checked against the If-Match header of the requests.
+	Version uint64 `json:"version,omitempty" db:"version"`
+	// DeletedAt is the time the collection was moved to the trash, nil if it was not deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// ValidateCollectionField check if a field exists in collection struct and can be filtered
+func ValidateCollectionField(f string) (exists bool) {
+	return validateField(reflect.TypeOf(Collection{}), f)
+}
+
+// ValidateCollectionSortField check if the collections can be ordered by a field
+func ValidateCollectionSortField(f string) bool {
+	return validateSortField(reflect.TypeOf(Collection{}), f)
+}
+
+// ValidateCollectionValue check if a value can be assigned to the field in collection struct
+func ValidateCollectionValue(f string, v string) (ok bool) {
+	return validateValue(reflect.TypeOf(Collection{}), f, v)
+}
+
+// ValidateCollectionOperator check if the filter operator can be applied to the field in collection struct
+func ValidateCollectionOperator(f string, op Operator) (ok bool) {
+	return validateOperator(reflect.TypeOf(Collection{}), f, op)
+}
+
+// validateField check if a field exists in the struct type and is stored in a column
+func validateField(t reflect.Type, f string) bool {
+	field, exists := t.FieldByName(f)
+	return exists && field.Tag.Get("db") != "-"
+}
+
+// validateSortField check if a field of the struct type is stored in a column and holds text, a number or a date, the values CompareResources can order.
+// This leaves out the deleted_at timestamp, which is nil for most resources.
+func validateSortField(t reflect.Type, f string) bool {
+	if !validateField(t, f) {
+		return false
+	}
+
+	field, _ := t.FieldByName(f)
+	if field.Type == reflect.TypeOf(Date{}) {
+		return true
+	}
+
+	switch field.Type.Kind() {
+	case reflect.String, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateOperator check if the operator can be applied to the field of the struct type: the ordering operators only apply to numeric and date fields
+// and the pattern operators to text fields
+func validateOperator(t reflect.Type, f string, op Operator) bool {
+	field, _ := t.FieldByName(f)
+
+	switch {
+	case op.Ordering():
+		switch field.Type {
+		case reflect.TypeOf(uint8(0)), reflect.TypeOf(uint64(0)), reflect.TypeOf(Date{}):
+			return true
+		}
+		return false
+	case op.Pattern():
+		return field.Type == reflect.TypeOf("")
+	default:
+		return true
+	}
+}
+
+// validateValue check if a value can be assigned to the field of the struct type
+func validateValue(t reflect.Type, f string, v string) bool {
+	field, _ := t.FieldByName(f)
 
 	var value reflect.Value
 	switch field.Type {
-	case reflect.TypeOf(uint8(0)):
-		num, err := strconv.ParseUint(v, 10, 8)
+	case reflect.TypeOf(uint8(0)), reflect.TypeOf(uint64(0)):
+		num, err := strconv.ParseUint(v, 10, field.Type.Bits())
 		if err != nil {
 			return false
 		}
-		value = reflect.ValueOf(uint8(num))
+		value = reflect.ValueOf(num).Convert(field.Type)
 	case reflect.TypeOf(Date{}):
 		t, err := time.Parse(dateLayout, v)
 		if err != nil {
@@ -113,7 +301,3 @@ func ValidateBookValue(f string, v string) (ok bool) {
 
 	return value.Type().AssignableTo(field.Type)
 }
-
-// Collection represents a set of books
-type Collection struct {
-}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"book-management/pkg/book-cli/pkg/options"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// addCmd adds a book to a collection
+var addCmd = &cobra.Command{
+	Use:   "add book",
+	Short: "add a book to a collection",
+	Long:  `used to add a book to a collection. Example: book-cli add book <ISBN> --to <COLLECTION>`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		collection, _ := cmd.Flags().GetString("to")
+		opts, err := options.NewMembershipOptions(options.Create, host, args, collection)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		return RunCommand(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+
+	addCmd.Flags().String("to", "", "name of the collection")
+	addCmd.MarkFlagRequired("to")
+}
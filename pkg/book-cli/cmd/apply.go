@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/book-cli/pkg/options"
+	"book-management/pkg/book-cli/pkg/validation"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// applyCmd creates a resource or updates it if it already exists
+var applyCmd = &cobra.Command{
+	Use:     "apply resource",
+	Short:   "create a resource or update it if it already exists",
+	Long:    `used to create resources or update the existing ones. Example: book-cli apply <TYPE> [OPTIONS] [ -f FILE-PATH | OBJECT]`,
+	PreRunE: PreModifierFunction,
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := options.NewModifierOptions(cmd, options.Create, host, args)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		if opts.Resource != apis.BookType {
+			return fmt.Errorf("Error: apply is not supported for %v", opts.Resource.Plural())
+		}
+
+		err = validation.ValidateResource(opts.Resource, opts.Object)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid resource definition: %v", err)
+		}
+
+		opts.Path = "/apply"
+		return RunCommand(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().StringP("file", "f", "", "path to JSON resource file")
+}
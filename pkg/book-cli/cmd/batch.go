@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/book-cli/pkg/options"
+	"book-management/pkg/book-cli/pkg/validation"
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+// addBatchFlags adds the flags used to send many resources with a single request to a modifier command
+func addBatchFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("batch", false, "the definition is a JSON array or a newline delimited JSON stream of books, applied in a single transaction")
+	cmd.Flags().Bool("best-effort", false, "with --batch, apply the valid books even if others fail")
+}
+
+// isBatch reports whether the command is sending a batch
+func isBatch(cmd *cobra.Command) bool {
+	batch, _ := cmd.Flags().GetBool("batch")
+	return batch
+}
+
+// setBatchOptions validates the batch definition and targets the options to the batch endpoint
+func setBatchOptions(cmd *cobra.Command, opts *options.CommandOptions) error {
+	err := validation.ValidateBatch(opts.Resource, opts.Object)
+
+	if err != nil {
+		return fmt.Errorf("Error: invalid batch definition: %v", err)
+	}
+
+	mode := apis.Atomic
+	if bestEffort, _ := cmd.Flags().GetBool("best-effort"); bestEffort {
+		mode = apis.BestEffort
+	}
+
+	opts.Path = "/batch"
+	opts.Params = url.Values{"mode": []string{string(mode)}}
+	return nil
+}
@@ -22,6 +22,13 @@ var createCmd = &cobra.Command{
 			return fmt.Errorf("Error: invalid options: %v", err)
 		}
 
+		if isBatch(cmd) {
+			if err = setBatchOptions(cmd, opts); err != nil {
+				return err
+			}
+			return RunCommand(opts)
+		}
+
 		err = validation.ValidateResource(opts.Resource, opts.Object)
 
 		if err != nil {
@@ -36,4 +43,5 @@ func init() {
 	rootCmd.AddCommand(createCmd)
 
 	createCmd.Flags().StringP("file", "f", "", "path to JSON resource file")
+	addBatchFlags(createCmd)
 }
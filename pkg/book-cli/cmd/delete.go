@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/book-cli/pkg/options"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// deleteCmd represents the delete command
+var deleteCmd = &cobra.Command{
+	Use:     "delete resource",
+	Short:   "delete a resource",
+	Long:    `used to delete resources. Example: book-cli delete <TYPE> [FLAGS|RESOURCE_IDENTIFIER]`,
+	PreRunE: PreRetrieverFunction,
+	Args:    cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := options.NewRetrieverOptions(cmd, options.Delete, host, args)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		// a resource identifier matches at most one resource
+		if !yes && len(args) == 1 {
+			confirmed, err := confirmDelete(opts)
+
+			if err != nil {
+				return fmt.Errorf("Error: %v", err)
+			}
+
+			if !confirmed {
+				fmt.Println("Aborted")
+				return nil
+			}
+		}
+
+		return RunCommand(opts)
+	},
+}
+
+// confirmDelete counts the resources matching the filters and asks for confirmation if more than one would be deleted
+func confirmDelete(opts *options.CommandOptions) (bool, error) {
+	getOpts := *opts
+	getOpts.Operation = options.Get
+	getOpts.Params = apis.ListOptions{Limit: 1}.Values()
+
+	res, err := sendRequest(&getOpts)
+
+	if err != nil {
+		return false, err
+	}
+
+	if res.Status != "success" {
+		return false, fmt.Errorf("retrieving matching %v: %v", opts.Resource.Plural(), res.Metadata)
+	}
+
+	page := apis.Page{}
+	err = json.Unmarshal([]byte(res.Metadata), &page)
+
+	if err != nil {
+		return false, fmt.Errorf("retrieving matching %v: %v", opts.Resource.Plural(), err)
+	}
+
+	if page.Total <= 1 {
+		return true, nil
+	}
+
+	fmt.Printf("%d %v match the filters. Delete them all? [y/N]: ", page.Total, opts.Resource.Plural())
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+func init() {
+	rootCmd.AddCommand(deleteCmd)
+
+	addFilterFlags(deleteCmd)
+	deleteCmd.Flags().BoolP("yes", "y", false, "delete without asking for confirmation when more than one resource matches")
+}
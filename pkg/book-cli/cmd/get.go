@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"book-management/pkg/apis"
 	"book-management/pkg/book-cli/pkg/options"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+// defaultPageSize is the page size used by --all-pages when --limit is not supplied
+const defaultPageSize = 100
+
 // getCmd represents the get command
 var getCmd = &cobra.Command{
 	Use:     "get a resource",
@@ -21,15 +29,116 @@ var getCmd = &cobra.Command{
 			return fmt.Errorf("Error: invalid options: %v", err)
 		}
 
+		listOpts, err := listOptions(cmd, opts.Resource)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		allPages, _ := cmd.Flags().GetBool("all-pages")
+
+		if allPages {
+			return runAllPages(opts, listOpts)
+		}
+
+		opts.Params = listOpts.Values()
 		return RunCommand(opts)
 	},
 }
 
+// listOptions validates the pagination flags and converts them into list options for the resource type
+func listOptions(cmd *cobra.Command, kind apis.ResourceType) (apis.ListOptions, error) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	page, _ := cmd.Flags().GetInt("page")
+	sort, _ := cmd.Flags().GetString("sort")
+	allPages, _ := cmd.Flags().GetBool("all-pages")
+	includeDeleted, _ := cmd.Flags().GetBool("include-deleted")
+	onlyDeleted, _ := cmd.Flags().GetBool("only-deleted")
+	asOf, _ := cmd.Flags().GetString("as-of")
+
+	if page != 0 && limit == 0 {
+		return apis.ListOptions{}, fmt.Errorf("--page requires --limit")
+	}
+
+	if page != 0 && allPages {
+		return apis.ListOptions{}, fmt.Errorf("--page and --all-pages cannot be used together")
+	}
+
+	values := url.Values{}
+	values.Set("limit", strconv.Itoa(limit))
+	values.Set("sort", sort)
+	if page > 1 {
+		values.Set("offset", strconv.Itoa((page-1)*limit))
+	}
+	values.Set("include_deleted", strconv.FormatBool(includeDeleted))
+	values.Set("only_deleted", strconv.FormatBool(onlyDeleted))
+	values.Set("as_of", asOf)
+
+	return apis.ParseListOptions(values, kind.Identifier(), kind.ValidateSortField)
+}
+
+// runAllPages retrieves and displays every page. It follows the next cursor when available and steps through the offsets otherwise.
+func runAllPages(opts *options.CommandOptions, listOpts apis.ListOptions) error {
+	if listOpts.Limit == 0 {
+		listOpts.Limit = defaultPageSize
+	}
+
+	for {
+		opts.Params = listOpts.Values()
+		res, err := sendRequest(opts)
+
+		if err != nil {
+			return err
+		}
+
+		displayResponse(opts, res)
+
+		if res.Status != "success" {
+			return nil
+		}
+
+		page := apis.Page{}
+		err = json.Unmarshal([]byte(res.Metadata), &page)
+
+		if err != nil {
+			return fmt.Errorf("something went wrong while reading page: %v", err)
+		}
+
+		switch {
+		case page.NextCursor != "":
+			listOpts.Cursor = page.NextCursor
+		case !listOpts.KeysetOrdered(opts.Resource.Identifier()) && int64(listOpts.Offset+listOpts.Limit) < page.Total:
+			listOpts.Offset += listOpts.Limit
+		default:
+			return nil
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(getCmd)
 
-	getCmd.Flags().String("author", "", "book author")
-	getCmd.Flags().String("title", "", "book title")
-	getCmd.Flags().String("genre", "", "book genre")
-	getCmd.Flags().String("dates", "", "range of published dates")
+	addFilterFlags(getCmd)
+	getCmd.Flags().Int("limit", 0, "maximum number of resources per page")
+	getCmd.Flags().Int("page", 0, "page to retrieve, starting from 1. Requires --limit")
+	getCmd.Flags().Bool("all-pages", false, "retrieve all the pages")
+	getCmd.Flags().String("sort", "", "comma separated fields used to order the resources, a leading '-' reverses the order. Example: -published_date,title")
+	getCmd.Flags().Bool("include-deleted", false, "retrieve the resources in the trash too")
+	getCmd.Flags().Bool("only-deleted", false, "retrieve only the resources in the trash")
+	getCmd.Flags().String("as-of", "", "retrieve the resources as they were at a time, either RFC 3339 (e.g. 2025-12-31T18:00:00Z) or a date standing for the end of that day in UTC (e.g. 2025-12-31)")
+}
+
+// addFilterFlags adds the flags used to filter resources to a retriever command
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("author", nil, "book author. Can be repeated to match any of the authors")
+	cmd.Flags().StringArray("title", nil, "book title. Can be repeated to match any of the titles")
+	cmd.Flags().StringArray("genre", nil, "book genre. Can be repeated to match any of the genres. Example: --genre drama --genre comedy")
+	cmd.Flags().String("dates", "", "range of published dates")
+	cmd.Flags().StringArray("edition", nil, "book edition, optionally preceded by a comparison operator among <, <=, >, >=, != and =. Can be repeated. Example: --edition '>2'")
+	cmd.Flags().StringArray("published", nil, "book published date (YYYY-MM-DD), optionally preceded by a comparison operator among <, <=, >, >=, != and =. Can be repeated. Example: --published '<1900-01-01'")
+	for _, field := range options.PatternFields {
+		for _, p := range options.PatternOperators {
+			cmd.Flags().String(field+"-"+p.Suffix, "", fmt.Sprintf("book %v %v the text, ignoring the case", field, strings.ReplaceAll(p.Suffix, "-", " ")))
+		}
+	}
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/book-cli/pkg/options"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// historyCmd shows the changes of a resource
+var historyCmd = &cobra.Command{
+	Use:   "history resource",
+	Short: "show the changes of a resource",
+	Long:  `used to show who changed a resource, when and which fields changed. Example: book-cli history <TYPE> <RESOURCE_IDENTIFIER>`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := options.NewHistoryOptions(host, args)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		res, err := sendRequest(opts)
+
+		if err != nil {
+			return err
+		}
+
+		if res.Status != "success" {
+			displayResponse(opts, res)
+			return nil
+		}
+
+		entries := []apis.HistoryEntry{}
+		err = json.Unmarshal([]byte(res.Metadata), &entries)
+
+		if err != nil {
+			return fmt.Errorf("something went wrong while reading history: %v", err)
+		}
+
+		displayHistory(entries)
+		return nil
+	},
+}
+
+// displayHistory prints the entries one after the other, each followed by its changed fields
+func displayHistory(entries []apis.HistoryEntry) {
+	for _, entry := range entries {
+		fmt.Printf("%v %v %v %v by %v\n", entry.ChangedAt.Format(time.RFC3339), entry.Operation, entry.Resource, entry.ID, entry.Principal)
+
+		for _, change := range entry.Changes {
+			fmt.Printf("    %v: %v -> %v\n", change.Field, fieldValue(change.Before), fieldValue(change.After))
+		}
+	}
+}
+
+// fieldValue formats the value of a field as JSON, so that strings are quoted and missing values are shown as null
+func fieldValue(v interface{}) string {
+	formatted, err := json.Marshal(v)
+
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(formatted)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
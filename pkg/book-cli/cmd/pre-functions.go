@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"book-management/pkg/book-cli/pkg/options"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -17,11 +18,26 @@ func PreModifierFunction(cmd *cobra.Command, args []string) error {
 // PreRetrieverFunction checks whether the resource identifier is passed as arg or at least one of the filtering args is supplied as flag
 func PreRetrieverFunction(cmd *cobra.Command, args []string) error {
 	if len(args) == 1 &&
-		cmd.Flag("author").Value.String() == "" &&
-		cmd.Flag("title").Value.String() == "" &&
+		!cmd.Flags().Changed("author") &&
+		!cmd.Flags().Changed("title") &&
 		cmd.Flag("dates").Value.String() == "" &&
-		cmd.Flag("genre").Value.String() == "" {
+		!cmd.Flags().Changed("genre") &&
+		!cmd.Flags().Changed("edition") &&
+		!cmd.Flags().Changed("published") &&
+		!patternFlagChanged(cmd) {
 		return fmt.Errorf("provide resource identifier or at least one valid filter")
 	}
 	return nil
 }
+
+// patternFlagChanged reports whether any of the pattern flags, e.g. --title-contains, is supplied
+func patternFlagChanged(cmd *cobra.Command) bool {
+	for _, field := range options.PatternFields {
+		for _, p := range options.PatternOperators {
+			if cmd.Flag(field+"-"+p.Suffix).Value.String() != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"book-management/pkg/book-cli/pkg/options"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// removeCmd removes a book from a collection
+var removeCmd = &cobra.Command{
+	Use:   "remove book",
+	Short: "remove a book from a collection",
+	Long:  `used to remove a book from a collection. The book itself is not deleted. Example: book-cli remove book <ISBN> --from <COLLECTION>`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		collection, _ := cmd.Flags().GetString("from")
+		opts, err := options.NewMembershipOptions(options.Delete, host, args, collection)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		return RunCommand(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+
+	removeCmd.Flags().String("from", "", "name of the collection")
+	removeCmd.MarkFlagRequired("from")
+}
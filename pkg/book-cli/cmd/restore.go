@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"book-management/pkg/book-cli/pkg/options"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd moves a resource out of the trash
+var restoreCmd = &cobra.Command{
+	Use:   "restore resource",
+	Short: "restore a deleted resource",
+	Long:  `used to restore a resource from the trash, until it is purged. Example: book-cli restore <TYPE> <RESOURCE_IDENTIFIER>`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := options.NewRestoreOptions(host, args)
+
+		if err != nil {
+			return fmt.Errorf("Error: invalid options: %v", err)
+		}
+
+		return RunCommand(opts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -13,6 +13,18 @@ import (
 
 // RunCommand performs http request to the book server for all operations.
 func RunCommand(opts *options.CommandOptions) error {
+	responseBody, err := sendRequest(opts)
+
+	if err != nil {
+		return err
+	}
+
+	displayResponse(opts, responseBody)
+	return nil
+}
+
+// sendRequest performs the http request described by the options and returns the server response
+func sendRequest(opts *options.CommandOptions) (apis.Message, error) {
 	httpClient := http.Client{
 		Timeout: 20 * time.Second,
 	}
@@ -20,34 +32,36 @@ func RunCommand(opts *options.CommandOptions) error {
 	req, err := http.NewRequest(string(opts.Operation), opts.URL(), bytes.NewBuffer([]byte(opts.Object)))
 
 	if err != nil {
-		return fmt.Errorf("something went wrong while creating request: %v", err)
+		return apis.Message{}, fmt.Errorf("something went wrong while creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	for key, values := range opts.Header {
+		req.Header[key] = values
+	}
 
 	fmt.Printf("Sending request to %v\n", opts.URL())
 	res, err := httpClient.Do(req)
 
 	if err != nil {
-		return fmt.Errorf("something went wrong while creating resource: %v", err)
+		return apis.Message{}, fmt.Errorf("something went wrong while creating resource: %v", err)
 	}
 
 	body, err := ioutil.ReadAll(res.Body)
 	defer res.Body.Close()
 
 	if err != nil {
-		return fmt.Errorf("something went wrong while reading response: %v", err)
+		return apis.Message{}, fmt.Errorf("something went wrong while reading response: %v", err)
 	}
 
 	responseBody := apis.Message{}
 	err = json.Unmarshal(body, &responseBody)
 
 	if err != nil {
-		return fmt.Errorf("something went wrong while unmarshalling response: %v", err)
+		return apis.Message{}, fmt.Errorf("something went wrong while unmarshalling response: %v", err)
 	}
 
-	displayResponse(opts, responseBody)
-	return nil
+	return responseBody, nil
 }
 
 func displayResponse(opts *options.CommandOptions, responseBody apis.Message) {
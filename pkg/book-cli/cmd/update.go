@@ -3,7 +3,10 @@ package cmd
 import (
 	"book-management/pkg/book-cli/pkg/options"
 	"book-management/pkg/book-cli/pkg/validation"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
 
 	"github.com/spf13/cobra"
 )
@@ -22,18 +25,46 @@ var updateCmd = &cobra.Command{
 			return fmt.Errorf("Error: invalid options: %v", err)
 		}
 
+		if isBatch(cmd) {
+			if err = setBatchOptions(cmd, opts); err != nil {
+				return err
+			}
+			return RunCommand(opts)
+		}
+
 		err = validation.ValidateResource(opts.Resource, opts.Object)
 
 		if err != nil {
 			return fmt.Errorf("Error: invalid resource definition: %v", err)
 		}
 
+		if force, _ := cmd.Flags().GetBool("force"); !force {
+			setIfMatch(opts)
+		}
+
 		return RunCommand(opts)
 	},
 }
 
+// setIfMatch makes the update conditional on the version of the object, if it has one because it was fetched from the server.
+// The server then rejects the update if the resource changed in the meantime.
+func setIfMatch(opts *options.CommandOptions) {
+	object := struct {
+		Version uint64 `json:"version"`
+	}{}
+
+	if json.Unmarshal([]byte(opts.Object), &object) != nil || object.Version == 0 {
+		return
+	}
+
+	opts.Header = http.Header{}
+	opts.Header.Set("If-Match", strconv.Quote(strconv.FormatUint(object.Version, 10)))
+}
+
 func init() {
 	rootCmd.AddCommand(updateCmd)
 
 	updateCmd.Flags().StringP("file", "f", "", "path to JSON resource file")
+	updateCmd.Flags().Bool("force", false, "update the resource even if it changed since its version was read")
+	addBatchFlags(updateCmd)
 }
@@ -4,9 +4,11 @@ import (
 	"book-management/pkg/apis"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
+	"github.com/iancoleman/strcase"
 	"github.com/spf13/cobra"
 )
 
@@ -32,6 +34,12 @@ type CommandOptions struct {
 	Operation ResourceOperation
 	Object    string
 	Filters   []string
+	// Params are additional query parameters, e.g. the pagination options
+	Params url.Values
+	// Path is appended to the resource URL to address a sub resource, e.g. the books of a collection
+	Path string
+	// Header holds additional request headers, e.g. the If-Match header of conditional updates
+	Header http.Header
 }
 
 // NewModifierOptions forms the options for a modifier command
@@ -63,35 +71,143 @@ func NewRetrieverOptions(cmd *cobra.Command, op ResourceOperation, host string,
 
 	// use only resource identifier if provided
 	if len(args) > 1 {
-		id = strings.ReplaceAll(strings.ToLower(args[1]), " ", "-")
-		return newCommandOptions(kind, op, "", host, []string{"isbn_eq_" + id}), nil
+		id = apis.EncodeFilterValue(args[1])
+		return newCommandOptions(kind, op, "", host, []string{strcase.ToSnake(kind.Identifier()) + "_eq_" + id}), nil
 	}
 
 	filters := []string{}
-	authorFlag := cmd.Flag("author").Value.String()
-	titleFlag := cmd.Flag("title").Value.String()
-	genreFlag := cmd.Flag("genre").Value.String()
 	datesFlag := cmd.Flag("dates").Value.String()
 
-	if authorFlag != "" {
-		filters = append(filters, "author_eq_"+strings.ReplaceAll(strings.ToLower(authorFlag), " ", "-"))
+	for _, field := range []string{"author", "title", "genre"} {
+		values, _ := cmd.Flags().GetStringArray(field)
+		if filter := anyOfFilter(field, values); filter != "" {
+			filters = append(filters, filter)
+		}
 	}
 
-	if titleFlag != "" {
-		filters = append(filters, "title_eq_"+strings.ReplaceAll(strings.ToLower(titleFlag), " ", "-"))
+	if datesFlag != "" {
+		filters = append(filters, "dates_eq_"+dateRangeValue(datesFlag))
 	}
 
-	if genreFlag != "" {
-		filters = append(filters, "genre_eq_"+strings.ReplaceAll(strings.ToLower(genreFlag), " ", "-"))
+	for _, f := range []struct{ flag, field string }{{"edition", "edition"}, {"published", "published-date"}} {
+		comparisons, _ := cmd.Flags().GetStringArray(f.flag)
+		for _, comparison := range comparisons {
+			filters = append(filters, comparisonFilter(f.field, comparison))
+		}
 	}
 
-	if datesFlag != "" {
-		filters = append(filters, "dates_eq_"+strings.ReplaceAll(strings.ToLower(datesFlag), " ", "-"))
+	for _, field := range PatternFields {
+		for _, p := range PatternOperators {
+			value, _ := cmd.Flags().GetString(field + "-" + p.Suffix)
+			if value != "" {
+				filters = append(filters, field+"_"+p.Op.String()+"_"+apis.EncodeFilterValue(value))
+			}
+		}
 	}
 
 	return newCommandOptions(kind, op, "", host, filters), nil
 }
 
+// anyOfFilter converts the values of a repeated flag into a filter of the field matching any of them: a single value is compared for equality,
+// several values with the in operator. It returns an empty string if there are no values.
+func anyOfFilter(field string, values []string) string {
+	var parts []string
+	for _, value := range values {
+		if value != "" {
+			parts = append(parts, apis.EncodeFilterValue(value))
+		}
+	}
+
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return field + "_" + apis.Equals.String() + "_" + parts[0]
+	default:
+		return field + "_" + apis.In.String() + "_" + strings.Join(parts, "|")
+	}
+}
+
+// PatternFields are the text fields that can be searched with the pattern flags
+var PatternFields = []string{"author", "title", "genre"}
+
+// PatternOperators maps the suffixes of the pattern flags, e.g. --title-contains, to the filter operators
+var PatternOperators = []struct {
+	Suffix string
+	Op     apis.Operator
+}{
+	{"contains", apis.Contains},
+	{"starts-with", apis.StartsWith},
+	{"ends-with", apis.EndsWith},
+}
+
+// comparisonOperators maps the prefixes of the comparison flags to the filter operators, the longest prefixes first
+var comparisonOperators = []struct {
+	prefix string
+	op     apis.Operator
+}{
+	{">=", apis.GreaterOrEqual},
+	{"<=", apis.LessOrEqual},
+	{"!=", apis.NotEqual},
+	{">", apis.GreaterThan},
+	{"<", apis.LessThan},
+	{"=", apis.Equals},
+}
+
+// comparisonFilter converts a comparison flag such as ">2" or "<1900-01-01" into a filter of the field. A value without operator is compared for equality.
+func comparisonFilter(field string, comparison string) string {
+	comparison = strings.TrimSpace(comparison)
+	op := apis.Equals
+
+	for _, c := range comparisonOperators {
+		if strings.HasPrefix(comparison, c.prefix) {
+			op = c.op
+			comparison = strings.TrimSpace(strings.TrimPrefix(comparison, c.prefix))
+			break
+		}
+	}
+
+	return field + "_" + op.String() + "_" + apis.EncodeFilterValue(comparison)
+}
+
+// dateRangeValue encodes the dates of a range such as "2020-01-01-to-2020-12-31", keeping the separator between them
+func dateRangeValue(dateRange string) string {
+	dates := strings.Split(dateRange, "-to-")
+	for i, date := range dates {
+		dates[i] = apis.EncodeFilterValue(strings.TrimSpace(date))
+	}
+	return strings.Join(dates, "-to-")
+}
+
+// NewMembershipOptions forms the options to add or remove a book, whose ISBN is the second arg, to the collection
+func NewMembershipOptions(op ResourceOperation, host string, args []string, collection string) (*CommandOptions, error) {
+	if apis.GetResource(args[0]) != apis.BookType {
+		return nil, fmt.Errorf("only books can be members of a collection")
+	}
+
+	if collection == "" {
+		return nil, fmt.Errorf("provide the collection name")
+	}
+
+	opts := newCommandOptions(apis.CollectionType, op, "", host, []string{})
+	opts.Path = fmt.Sprintf("/%s/%s/%s", url.PathEscape(collection), apis.BookType.Plural(), url.PathEscape(args[1]))
+	return opts, nil
+}
+
+// NewRestoreOptions forms the options to restore the resource, whose identifier is the second arg, from the trash
+func NewRestoreOptions(host string, args []string) (*CommandOptions, error) {
+	opts := newCommandOptions(apis.GetResource(args[0]), Create, "", host, []string{})
+	opts.Path = fmt.Sprintf("/%s/restore", url.PathEscape(args[1]))
+	return opts, nil
+}
+
+// NewHistoryOptions forms the options to retrieve the changes of the resource whose identifier is the second arg
+func NewHistoryOptions(host string, args []string) (*CommandOptions, error) {
+	opts := newCommandOptions(apis.GetResource(args[0]), Get, "", host, []string{})
+	opts.Path = fmt.Sprintf("/%s/history", url.PathEscape(args[1]))
+	return opts, nil
+}
+
 func newCommandOptions(kind apis.ResourceType, op ResourceOperation, obj string, server string, filters []string) *CommandOptions {
 	return &CommandOptions{
 		Server:    server,
@@ -104,12 +220,18 @@ func newCommandOptions(kind apis.ResourceType, op ResourceOperation, obj string,
 
 // URL forms the correct URL for a command
 func (opts *CommandOptions) URL() string {
-	baseURL := fmt.Sprintf("http://%s/api/v1/%s", opts.Server, opts.Resource.Plural())
-	if len(opts.Filters) == 0 {
-		return baseURL
-	}
+	baseURL := fmt.Sprintf("http://%s/api/v1/%s%s", opts.Server, opts.Resource.Plural(), opts.Path)
 
-	baseFilterURL := fmt.Sprintf("%s?filter=", baseURL)
+	var query []string
+	if len(opts.Filters) != 0 {
+		query = append(query, "filter="+url.QueryEscape(strings.Join(opts.Filters, "_and_")))
+	}
+	if len(opts.Params) != 0 {
+		query = append(query, opts.Params.Encode())
+	}
 
-	return baseFilterURL + strings.Join(opts.Filters, "_and_")
+	if len(query) == 0 {
+		return baseURL
+	}
+	return baseURL + "?" + strings.Join(query, "&")
 }
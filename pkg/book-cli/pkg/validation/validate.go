@@ -4,6 +4,7 @@ import (
 	"book-management/pkg/apis"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // ValidateResource ensures that input string can be unmarshaled in the correct data structure
@@ -19,3 +20,17 @@ func ValidateResource(kind apis.ResourceType, obj string) error {
 		return fmt.Errorf("unsupported type")
 	}
 }
+
+// ValidateBatch ensures that input string can be unmarshaled in a batch of resources of the given type
+func ValidateBatch(kind apis.ResourceType, obj string) error {
+	switch kind {
+	case apis.BookType:
+		books, err := apis.DecodeBooks(strings.NewReader(obj))
+		if err == nil && len(books) == 0 {
+			return fmt.Errorf("empty batch")
+		}
+		return err
+	default:
+		return fmt.Errorf("batches of %v are not supported", kind.Plural())
+	}
+}
@@ -0,0 +1,3 @@
+-- Creates the empty database. Tables are managed by the migrations embedded in book-server:
+-- run `book-server -driver postgres migrate up` after this script.
+SELECT 'CREATE DATABASE book_management' WHERE NOT EXISTS (SELECT FROM pg_database WHERE datname = 'book_management')\gexec
@@ -1,8 +1,51 @@
 package main
 
-import "book-management/pkg/server/pkg/rest"
+import (
+	"book-management/pkg/server/pkg/rest"
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// debugAddr is the address serving the runtime statistics, such as the ones of the cache, at /debug/vars
+var debugAddr string
+
+func init() {
+	flag.StringVar(&debugAddr, "debug-addr", "", "Address serving the runtime statistics at /debug/vars, e.g. 127.0.0.1:8081. Disabled if empty.")
+}
 
 func main() {
-	s := rest.NewBookServer()
-	s.Start()
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "":
+		if debugAddr != "" {
+			debug := http.NewServeMux()
+			debug.Handle("/debug/vars", expvar.Handler())
+			go func() {
+				log.Fatal(http.ListenAndServe(debugAddr, debug))
+			}()
+		}
+
+		s := rest.NewBookServer()
+		s.Start()
+	case "migrate":
+		err := runMigrate(flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "purge":
+		err := runPurge(flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %v\n", flag.Arg(0))
+		os.Exit(2)
+	}
 }
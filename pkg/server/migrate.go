@@ -0,0 +1,66 @@
+package main
+
+import (
+	"book-management/pkg/server/pkg/db"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: book-server [FLAGS] migrate up|down|status"
+
+// runMigrate executes the migrate subcommand against the database selected by the flags
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	migrator, err := db.NewMigrator(db.NewDBOptions())
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("db schema is up to date")
+		}
+	case "down":
+		m, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			fmt.Println("no migration to roll back")
+			return nil
+		}
+		fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// batchChunkSize is the number of rows written by a single multi-row insert or looked up by a single query
+const batchChunkSize = 100
+
+// prepareBatch validates the books of a batch and records the failures in results. It returns the indexes of the books to write.
+func prepareBatch(books []apis.Book, results []error) (pending []int) {
+	seen := make(map[string]bool, len(books))
+
+	for i := range books {
+		if err := validateBook(&books[i]); err != nil {
+			results[i] = err
+			continue
+		}
+
+		key := isbnKey(books[i].Isbn)
+		if seen[key] {
+			results[i] = fmt.Errorf("%w: book with ISBN %v is repeated in the batch", ErrConflict, books[i].Isbn)
+			continue
+		}
+		seen[key] = true
+		pending = append(pending, i)
+	}
+
+	return pending
+}
+
+// abortBatch reports whether an atomic batch has failed items and must be rolled back. In that case the other items are marked as not applied.
+func abortBatch(mode apis.BatchMode, results []error) bool {
+	if mode != apis.Atomic {
+		return false
+	}
+
+	failed := false
+	for _, err := range results {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+
+	if !failed {
+		return false
+	}
+
+	for i := range results {
+		if results[i] == nil {
+			results[i] = fmt.Errorf("%w: another book of the batch failed", ErrAborted)
+		}
+	}
+	return true
+}
+
+// CreateBooks creates the books of the batch in a single transaction using multi-row inserts.
+// It returns the outcome of each book, nil meaning created. The error is only returned if the whole batch failed.
+func (s *sqlHandler) CreateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	results = make([]error, len(books))
+	pending := prepareBatch(books, results)
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := s.readKeys(ctx, tx, bookTable, batchIsbns(books, pending), apis.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	var insert []int
+	for _, i := range pending {
+		if existing[isbnKey(books[i].Isbn)] != nil {
+			results[i] = fmt.Errorf("%w: book with ISBN %v already exists", ErrConflict, books[i].Isbn)
+			continue
+		}
+		insert = append(insert, i)
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	for start := 0; start < len(insert); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(insert) {
+			end = len(insert)
+		}
+
+		if err = s.insertChunk(ctx, tx, books, insert[start:end], results); err != nil {
+			return nil, err
+		}
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	if err = s.recordBatch(ctx, tx, apis.HistoryCreate, books, insert, results, nil); err != nil {
+		return nil, err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return nil, s.queryError(ctx, "commit transaction", err)
+	}
+
+	return results, nil
+}
+
+// insertChunk writes the books with a single multi-row insert. If it fails, the books are inserted one by one to find out the failing ones.
+func (s *sqlHandler) insertChunk(ctx context.Context, tx *sql.Tx, books []apis.Book, chunk []int, results []error) error {
+	var values []interface{}
+	for _, i := range chunk {
+		values = append(values, bookTable.insertValues(&books[i])...)
+	}
+
+	itemErr, err := s.savepoint(ctx, tx, func() error {
+		_, err := tx.ExecContext(ctx, bookTable.insertRowsStatement(s.dialect, len(chunk)), values...)
+		return err
+	})
+
+	if err != nil || itemErr == nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, bookTable.insertStatement(s.dialect))
+	if err != nil {
+		return s.queryError(ctx, "prepare statement", err)
+	}
+	defer stmt.Close()
+
+	for _, i := range chunk {
+		itemErr, err = s.savepoint(ctx, tx, func() error {
+			_, err := stmt.ExecContext(ctx, bookTable.insertValues(&books[i])...)
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if itemErr != nil {
+			if results[i] = s.queryError(ctx, "execute statement", itemErr); ctx.Err() != nil {
+				return results[i]
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateBooks updates the books of the batch in a single transaction reusing the same prepared statement.
+// It returns the outcome of each book, nil meaning updated. The error is only returned if the whole batch failed.
+func (s *sqlHandler) UpdateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	results = make([]error, len(books))
+	pending := prepareBatch(books, results)
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := s.readKeys(ctx, tx, bookTable, batchIsbns(books, pending), apis.ExcludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	var update []int
+	for _, i := range pending {
+		if existing[isbnKey(books[i].Isbn)] == nil {
+			results[i] = fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, books[i].Isbn)
+			continue
+		}
+		update = append(update, i)
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, bookTable.updateStatement(s.dialect, false))
+	if err != nil {
+		return nil, s.queryError(ctx, "prepare statement", err)
+	}
+	defer stmt.Close()
+
+	for _, i := range update {
+		itemErr, err := s.savepoint(ctx, tx, func() error {
+			_, err := stmt.ExecContext(ctx, bookTable.updateValues(&books[i], false)...)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if itemErr != nil {
+			if results[i] = s.queryError(ctx, "execute statement", itemErr); ctx.Err() != nil {
+				return nil, results[i]
+			}
+		}
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	if err = s.recordBatch(ctx, tx, apis.HistoryUpdate, books, update, results, existing); err != nil {
+		return nil, err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return nil, s.queryError(ctx, "commit transaction", err)
+	}
+
+	return results, nil
+}
+
+// batchIsbns returns the ISBNs of the pending books
+func batchIsbns(books []apis.Book, pending []int) []string {
+	isbns := make([]string, len(pending))
+	for j, i := range pending {
+		isbns[j] = books[i].Isbn
+	}
+	return isbns
+}
+
+// recordBatch records in the history the changes of the written books of the batch, nil results meaning written.
+// before holds the previous states indexed by historyID, nil for created books.
+func (s *sqlHandler) recordBatch(ctx context.Context, tx *sql.Tx, op apis.HistoryOperation, books []apis.Book, written []int, results []error, before map[string]interface{}) error {
+	var applied []int
+	for _, i := range written {
+		if results[i] == nil {
+			applied = append(applied, i)
+		}
+	}
+
+	after, err := s.readKeys(ctx, tx, bookTable, batchIsbns(books, applied), apis.ExcludeDeleted)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range applied {
+		key := isbnKey(books[i].Isbn)
+		if err = s.record(ctx, tx, bookTable, op, books[i].Isbn, before[key], after[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateBooks(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "batch.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	// the trigger makes the multi-row insert fail, so that the books are inserted one by one
+	_, err = handler.db.Exec("CREATE TRIGGER reject_book BEFORE INSERT ON books WHEN NEW.isbn = 3 BEGIN SELECT RAISE(ABORT, 'rejected'); END")
+	require.Nil(t, err)
+
+	ctx := context.Background()
+	books := []apis.Book{{Isbn: "1"}, {Isbn: "2"}, {Isbn: "3"}, {Isbn: "01"}, {Isbn: "isbn"}}
+
+	results, err := handler.CreateBooks(ctx, books, apis.Atomic)
+	require.Nil(t, err)
+	require.ErrorIs(t, results[0], ErrAborted)
+	require.ErrorIs(t, results[1], ErrAborted)
+	require.Error(t, results[2])
+	require.ErrorIs(t, results[3], ErrConflict)
+	require.ErrorIs(t, results[4], ErrValidation)
+
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int64(0), page.Total)
+
+	results, err = handler.CreateBooks(ctx, books, apis.BestEffort)
+	require.Nil(t, err)
+	require.Nil(t, results[0])
+	require.Nil(t, results[1])
+	require.Error(t, results[2])
+	require.ErrorIs(t, results[3], ErrConflict)
+
+	page, err = handler.GetBook(ctx, allBooks(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int64(2), page.Total)
+
+	results, err = handler.UpdateBooks(ctx, []apis.Book{{Isbn: "1", Title: "updated"}, {Isbn: "3"}}, apis.BestEffort)
+	require.Nil(t, err)
+	require.Nil(t, results[0])
+	require.ErrorIs(t, results[1], ErrNotFound)
+
+	// only the written books are recorded, the ISBN being compared by value
+	entries, err := handler.History(ctx, apis.BookType, "001")
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, apis.HistoryCreate, entries[0].Operation)
+	require.Equal(t, apis.HistoryUpdate, entries[1].Operation)
+	require.Equal(t, "title", entries[1].Changes[0].Field)
+
+	_, err = handler.History(ctx, apis.BookType, "3")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBatchComparesIsbnsByValue(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "batch.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	for _, h := range []Handler{handler, NewMemoryHandler()} {
+		_, err = h.CreateBook(ctx, &apis.Book{Isbn: "123"})
+		require.Nil(t, err)
+
+		results, err := h.CreateBooks(ctx, []apis.Book{{Isbn: "0123"}}, apis.BestEffort)
+		require.Nil(t, err)
+		require.ErrorIs(t, results[0], ErrConflict)
+
+		results, err = h.UpdateBooks(ctx, []apis.Book{{Isbn: "0123", Title: "updated"}}, apis.BestEffort)
+		require.Nil(t, err)
+		require.Nil(t, results[0])
+
+		page, err := h.GetBook(ctx, allBooks(t), apis.ListOptions{})
+		require.Nil(t, err)
+		require.Equal(t, int64(1), page.Total)
+		require.Equal(t, "updated", page.Items[0].Title)
+	}
+}
+
+// allBooks returns a filter chain matching every book
+func allBooks(t *testing.T) *apis.FilterChain {
+	filters, err := apis.ParseFilters("title_ne_none", apis.ValidateBookField, apis.ValidateBookValue)
+	require.Nil(t, err)
+	return filters
+}
@@ -2,116 +2,182 @@ package db
 
 import (
 	"book-management/pkg/apis"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
-// Handler wraps the standard operation of the REST application for interacting with the database
+// Handler wraps the standard operation of the REST application for interacting with the database.
+// Operations stop as soon as the context is done: in that case the returned error wraps the context error.
 type Handler interface {
-	CreateBook(book *apis.Book) (message string, err error)
-	UpdateBook(book *apis.Book) (message string, err error)
-	GetBook(filters *apis.FilterChain) (books []apis.Book, err error)
+	CreateBook(ctx context.Context, book *apis.Book) (message string, err error)
+	UpdateBook(ctx context.Context, book *apis.Book) (message string, err error)
+	// ApplyBook creates the book or updates it if it already exists. created reports which of the two happened.
+	ApplyBook(ctx context.Context, book *apis.Book) (message string, created bool, err error)
+	// PatchBook changes the book with patch, which gets the book as stored, and updates it provided it is still at version. The book is read and
+	// updated in a single transaction, so that the changes are never merged into a stale copy. The error of patch is returned as is.
+	PatchBook(ctx context.Context, isbn string, version uint64, patch func(book *apis.Book) error) (message string, patched *apis.Book, err error)
+	GetBook(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.BookPage, err error)
+	// DeleteBook moves the books to the trash, where they stay until they are restored with RestoreBook or removed with Purge.
+	// A non-zero version makes the delete conditional: the filters must match a single book, which is only moved if it is still at that version.
+	DeleteBook(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error)
+	RestoreBook(ctx context.Context, isbn string) (message string, err error)
+	// CreateBooks and UpdateBooks apply a batch of books in a single transaction and return the outcome of each book, nil meaning applied
+	CreateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error)
+	UpdateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error)
+	CreateCollection(ctx context.Context, collection *apis.Collection) (message string, err error)
+	UpdateCollection(ctx context.Context, collection *apis.Collection) (message string, err error)
+	GetCollection(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.CollectionPage, err error)
+	DeleteCollection(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error)
+	RestoreCollection(ctx context.Context, name string) (message string, err error)
+	AddBookToCollection(ctx context.Context, name string, isbn string) (message string, err error)
+	RemoveBookFromCollection(ctx context.Context, name string, isbn string) (message string, err error)
+	// Purge permanently removes the books and the collections moved to the trash before the given time
+	Purge(ctx context.Context, before time.Time) (books int64, collections int64, err error)
+	// History returns the changes of a book or a collection in the order they happened. Every change made through the Handler is recorded
+	// together with the principal named by the context, see WithPrincipal.
+	History(ctx context.Context, kind apis.ResourceType, id string) (entries []apis.HistoryEntry, err error)
+	// Close releases the connections to the database and its replicas, and stops their health checks
+	Close() error
 }
 
-// MySQLHandler is the wrapper for the MySQL database
-type MySQLHandler struct {
-	db *sql.DB
-}
-
-// NewMySQLHandler returns a new MySQLHandler and set up the connection to the database
-func NewMySQLHandler(opts Options) (*MySQLHandler, error) {
-	handler := &MySQLHandler{}
-
-	config := mysql.NewConfig()
-	config.Addr = opts.Address()
-	config.User = opts.User
-	config.Passwd = opts.Pass
-	config.DBName = opts.DB
-	config.ParseTime = true
-	config.Loc = time.UTC
+// NewHandler returns the Handler implementation selected by the driver option, wrapped in a CachingHandler if the cache is enabled.
+// The statistics of the cache are published as the db_cache expvar variable.
+func NewHandler(opts Options) (Handler, error) {
+	if opts.CacheSize <= 0 {
+		return newDriverHandler(opts)
+	}
 
-	connector, err := mysql.NewConnector(config)
+	handler, err := newDriverHandler(opts)
 	if err != nil {
-		return nil, fmt.Errorf("set up db connection: %v", err)
+		return nil, err
 	}
 
-	handler.db = sql.OpenDB(connector)
-
-	return handler, nil
+	cache := NewCachingHandler(handler, opts.CacheSize, opts.CacheTTL)
+	publishCacheStats(cache)
+	return cache, nil
 }
 
-// CreateBook creates a new book in the database
-func (s *MySQLHandler) CreateBook(book *apis.Book) (message string, err error) {
-	stmt, err := s.db.Prepare("INSERT INTO books (title, author, description, isbn, published_date, edition, genre) VALUES (?, ?, ?, ?, ?, ?, ?)")
-
-	if err != nil {
-		fmt.Println(fmt.Errorf("prepare statement: %v", err))
-		return "", fmt.Errorf("internal error")
+// newDriverHandler returns the Handler implementation selected by the driver option
+func newDriverHandler(opts Options) (Handler, error) {
+	switch opts.Driver {
+	case MySQLDriver:
+		return NewMySQLHandler(opts)
+	case PostgresDriver:
+		return NewPostgresHandler(opts)
+	case SQLiteDriver:
+		return NewSQLiteHandler(opts)
+	case MemoryDriver:
+		return NewMemoryHandler(), nil
+	default:
+		return nil, fmt.Errorf("unsupported db driver: %v", opts.Driver)
 	}
-	defer stmt.Close()
+}
 
-	_, err = stmt.Exec(book.Title, book.Author, book.Description, book.Isbn, book.PublishedDate.String(), int64(book.Edition), book.Genre)
-	if err != nil {
-		fmt.Println(fmt.Errorf("execute statement: %v", err))
-		return "", fmt.Errorf("internal error")
+// openDB opens the connection to the SQL database selected by the driver option
+func openDB(opts Options) (*sql.DB, apis.Dialect, error) {
+	switch opts.Driver {
+	case MySQLDriver:
+		db, err := openMySQL(opts)
+		return db, apis.MySQL, err
+	case PostgresDriver:
+		db, err := openPostgres(opts)
+		return db, apis.Postgres, err
+	case SQLiteDriver:
+		db, err := openSQLite(opts)
+		return db, apis.SQLite, err
+	default:
+		return nil, nil, fmt.Errorf("db driver %v does not support migrations", opts.Driver)
 	}
+}
 
-	return fmt.Sprintf("Created book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+// MySQLHandler is the wrapper for the MySQL database
+type MySQLHandler struct {
+	sqlHandler
 }
 
-// UpdateBook updates an existing book in the database
-func (s *MySQLHandler) UpdateBook(book *apis.Book) (message string, err error) {
-	stmt, err := s.db.Prepare("UPDATE books SET title = ?, author = ?, description = ?, published_date = ?, edition = ?, genre = ? WHERE isbn = ?")
+// NewMySQLHandler returns a new MySQLHandler and set up the connection to the database and its replicas. It fails if the database schema is not up to date.
+func NewMySQLHandler(opts Options) (*MySQLHandler, error) {
+	db, err := openMySQL(opts)
+	if err != nil {
+		return nil, err
+	}
 
+	err = checkSchema(db, apis.MySQL)
 	if err != nil {
-		fmt.Println(fmt.Errorf("prepare statement: %v", err))
-		return "", fmt.Errorf("internal error")
+		db.Close()
+		return nil, err
 	}
-	defer stmt.Close()
 
-	_, err = stmt.Exec(book.Title, book.Author, book.Description, book.PublishedDate.String(), int64(book.Edition), book.Genre, book.Isbn)
+	replicas, err := openReplicas(opts, mysqlConnector, mysqlReplicaLag)
 	if err != nil {
-		fmt.Println(fmt.Errorf("execute statement: %v", err))
-		return "", fmt.Errorf("internal error")
+		db.Close()
+		return nil, err
 	}
 
-	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+	handler := &MySQLHandler{}
+	handler.db = db
+	handler.dialect = apis.MySQL
+	handler.classify = classifyMySQLError
+	handler.stateColumn = mysqlStateColumn
+	// the reads of a repeatable read transaction share the snapshot taken by the first one
+	handler.readOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	handler.timeout = opts.QueryTimeout
+	handler.replicas = replicas
+
+	return handler, nil
 }
 
-// GetBook returns one or more book from the database based on supplied filters
-func (s *MySQLHandler) GetBook(filters *apis.FilterChain) (books []apis.Book, err error) {
-	prepare, query := filters.SQLStatement()
-	qs := fmt.Sprintf("SELECT * FROM books WHERE %s", prepare)
+// openMySQL sets up the connection to the MySQL database and waits until it is reachable
+func openMySQL(opts Options) (*sql.DB, error) {
+	connector, err := mysqlConnector(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	stmt, err := s.db.Prepare(qs)
+	return connect(sql.OpenDB(connector), opts)
+}
 
+// mysqlConnector returns the connector to the MySQL database
+func mysqlConnector(opts Options) (driver.Connector, error) {
+	config, err := mysqlConfig(opts)
 	if err != nil {
-		fmt.Println(fmt.Errorf("prepare statement: %v", err))
-		return nil, fmt.Errorf("internal error")
+		return nil, fmt.Errorf("set up db connection: %v", err)
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.Query(query...)
+	connector, err := mysql.NewConnector(config)
 	if err != nil {
-		fmt.Println(fmt.Errorf("execute statement: %v", err))
-		return nil, fmt.Errorf("internal error")
+		return nil, fmt.Errorf("set up db connection: %v", err)
 	}
-	defer rows.Close()
+	return connector, nil
+}
 
-	for rows.Next() {
-		book := apis.Book{}
+// mysqlConfig returns the configuration of the connection to the MySQL database, with the additional DSN parameters of the options
+func mysqlConfig(opts Options) (*mysql.Config, error) {
+	config := mysql.NewConfig()
+	config.Net = "tcp"
+	config.Addr = opts.Address()
+	config.User = opts.User
+	config.Passwd = opts.Pass
+	config.DBName = opts.DB
 
-		err = rows.Scan(&book.Title, &book.Isbn, &book.Author, &book.PublishedDate, &book.Edition, &book.Description, &book.Genre)
-		if err != nil {
-			fmt.Println(fmt.Errorf("scan row: %v", err))
-			return nil, fmt.Errorf("internal error")
+	if opts.DSNParams != "" {
+		// the address must be part of the DSN, since some parameters depend on it (e.g. the server name checked by tls)
+		var err error
+		if config, err = mysql.ParseDSN(config.FormatDSN() + "?" + opts.DSNParams); err != nil {
+			return nil, err
 		}
-
-		books = append(books, book)
 	}
 
-	return books, nil
+	// the parameters the handler relies on cannot be overridden
+	config.ParseTime = true
+	config.Loc = time.UTC
+	// report the matched rows instead of the changed ones, otherwise updates leaving a book unchanged would look like missing books
+	config.ClientFoundRows = true
+
+	return config, nil
 }
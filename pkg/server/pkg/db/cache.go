@@ -0,0 +1,353 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"container/list"
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// CacheStats reports the activity of a CachingHandler
+type CacheStats struct {
+	// Hits is the number of listings served from the cache
+	Hits int64 `json:"hits"`
+	// Misses is the number of listings read from the wrapped Handler, including the ones that cannot be cached
+	Misses int64 `json:"misses"`
+	// Evictions is the number of entries dropped to make room for newer ones
+	Evictions int64 `json:"evictions"`
+	// Entries is the number of entries in the cache
+	Entries int `json:"entries"`
+}
+
+// CachingHandler is a Handler serving the book and collection listings read recently from memory. Every other operation goes to the wrapped Handler.
+// It keeps at most size listings, each one for at most ttl, and drops the listings a write made through it may have changed:
+// changes made by other servers or directly in the database show up only once the listings expire.
+// Listings of a single book are keyed by its ISBN, so that writing a book keeps the listings of the other ones. Listings as of a past time are not cached.
+// Writes drop the listings even when they fail, since a failure may be reported after the change was made (e.g. on a timeout).
+// The listings are neither served nor cached for a client whose reads stick to the primary after its writes, as the cached ones may come from a lagging replica.
+// Every method of Handler is forwarded explicitly rather than through embedding, so that a new method does not compile until its effect on the cache is decided.
+type CachingHandler struct {
+	handler Handler
+
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// generations counts the invalidations of each kind of resource, so that a listing read while the resources changed is not cached
+	generations map[apis.ResourceType]uint64
+	stats       CacheStats
+}
+
+var _ Handler = (*CachingHandler)(nil)
+
+// ownWritesReader is implemented by the Handlers sending the reads of a client to the primary for a while after its writes, see Options.ReadYourWrites
+type ownWritesReader interface {
+	readsOwnWrites(ctx context.Context) bool
+}
+
+// cacheEntry is a listing kept by the CachingHandler
+type cacheEntry struct {
+	key  string
+	kind apis.ResourceType
+	// isbn is the normalized ISBN of the book listed alone, empty for the other listings
+	isbn    string
+	page    interface{}
+	expires time.Time
+}
+
+// NewCachingHandler returns a CachingHandler wrapping handler. A zero ttl keeps the listings until they are evicted or invalidated.
+func NewCachingHandler(handler Handler, size int, ttl time.Duration) *CachingHandler {
+	return &CachingHandler{
+		handler:     handler,
+		size:        size,
+		ttl:         ttl,
+		now:         time.Now,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+		generations: make(map[apis.ResourceType]uint64),
+	}
+}
+
+// publishCache makes sure the cache statistics are published only once, expvar refusing duplicated variables
+var publishCache sync.Once
+
+// publishCacheStats publishes the statistics of the cache as the db_cache expvar variable
+func publishCacheStats(c *CachingHandler) {
+	publishCache.Do(func() {
+		expvar.Publish("db_cache", expvar.Func(func() interface{} {
+			return c.Stats()
+		}))
+	})
+}
+
+// Stats returns the statistics of the cache
+func (c *CachingHandler) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// bypass reports whether the listing must be read from the wrapped Handler without going through the cache:
+// listings as of a past time, and the ones of a client that must see its own writes
+func (c *CachingHandler) bypass(ctx context.Context, opts apis.ListOptions) bool {
+	if !opts.AsOf.IsZero() {
+		return true
+	}
+
+	reader, ok := c.handler.(ownWritesReader)
+	return ok && reader.readsOwnWrites(ctx)
+}
+
+// cacheKey returns the key of the listing and, for the listings of a single book, its normalized ISBN
+func cacheKey(kind apis.ResourceType, filters *apis.FilterChain, opts apis.ListOptions) (key string, isbn string) {
+	selection := "filter:" + filters.Canonical()
+
+	if kind == apis.BookType {
+		if converter, ok := filters.Get(kind.Identifier()); ok {
+			if filter, ok := converter.(*apis.Filter); ok && filter.Operation == apis.Equals {
+				isbn = isbnKey(filter.Value)
+				selection = "isbn:" + isbn
+			}
+		}
+	}
+
+	return kind.String() + "|" + selection + "|" + opts.Values().Encode(), isbn
+}
+
+// lookup returns the page cached with the key, if any and not expired. Otherwise it returns the generation the listing has to be read in to be cached.
+func (c *CachingHandler) lookup(kind apis.ResourceType, key string) (page interface{}, generation uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.entries[key]; found {
+		entry := e.Value.(*cacheEntry)
+		if c.ttl == 0 || c.now().Before(entry.expires) {
+			c.lru.MoveToFront(e)
+			c.stats.Hits++
+			return entry.page, 0, true
+		}
+		c.remove(e)
+	}
+
+	c.stats.Misses++
+	return nil, c.generations[kind], false
+}
+
+// store caches the page unless the resources of the kind changed since the generation it was read in
+func (c *CachingHandler) store(kind apis.ResourceType, key string, isbn string, generation uint64, page interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[kind] != generation {
+		return
+	}
+
+	if e, found := c.entries[key]; found {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		kind:    kind,
+		isbn:    isbn,
+		page:    page,
+		expires: c.now().Add(c.ttl),
+	})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry. The caller must hold the lock.
+func (c *CachingHandler) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// invalidate drops the listings of the kind. If isbns are given, only the listings of other books than those are kept.
+func (c *CachingHandler) invalidate(kind apis.ResourceType, isbns ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := make(map[string]bool, len(isbns))
+	for _, isbn := range isbns {
+		changed[isbnKey(isbn)] = true
+	}
+
+	c.generations[kind]++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		entry := e.Value.(*cacheEntry)
+		if entry.kind == kind && (len(isbns) == 0 || entry.isbn == "" || changed[entry.isbn]) {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+// GetBook returns the page from the cache, or reads it from the wrapped Handler and caches it.
+// The pages returned are shared with the other callers of the same listing and must not be modified.
+func (c *CachingHandler) GetBook(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (*apis.BookPage, error) {
+	if c.bypass(ctx, opts) {
+		return c.handler.GetBook(ctx, filters, opts)
+	}
+
+	key, isbn := cacheKey(apis.BookType, filters, opts)
+	cached, generation, ok := c.lookup(apis.BookType, key)
+	if ok {
+		return cached.(*apis.BookPage), nil
+	}
+
+	page, err := c.handler.GetBook(ctx, filters, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(apis.BookType, key, isbn, generation, page)
+	return page, nil
+}
+
+// GetCollection returns the page from the cache, or reads it from the wrapped Handler and caches it.
+// As for GetBook, the pages returned must not be modified.
+func (c *CachingHandler) GetCollection(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (*apis.CollectionPage, error) {
+	if c.bypass(ctx, opts) {
+		return c.handler.GetCollection(ctx, filters, opts)
+	}
+
+	key, _ := cacheKey(apis.CollectionType, filters, opts)
+	cached, generation, ok := c.lookup(apis.CollectionType, key)
+	if ok {
+		return cached.(*apis.CollectionPage), nil
+	}
+
+	page, err := c.handler.GetCollection(ctx, filters, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(apis.CollectionType, key, "", generation, page)
+	return page, nil
+}
+
+// CreateBook stores a new book and drops the listings that may include it
+func (c *CachingHandler) CreateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	defer c.invalidate(apis.BookType, book.Isbn)
+	return c.handler.CreateBook(ctx, book)
+}
+
+// UpdateBook replaces an existing book and drops the listings that may include it
+func (c *CachingHandler) UpdateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	defer c.invalidate(apis.BookType, book.Isbn)
+	return c.handler.UpdateBook(ctx, book)
+}
+
+// ApplyBook stores or replaces the book and drops the listings that may include it
+func (c *CachingHandler) ApplyBook(ctx context.Context, book *apis.Book) (message string, created bool, err error) {
+	defer c.invalidate(apis.BookType, book.Isbn)
+	return c.handler.ApplyBook(ctx, book)
+}
+
+// PatchBook changes the book and drops the listings that may include it
+func (c *CachingHandler) PatchBook(ctx context.Context, isbn string, version uint64, patch func(book *apis.Book) error) (message string, patched *apis.Book, err error) {
+	defer c.invalidate(apis.BookType, isbn)
+	return c.handler.PatchBook(ctx, isbn, version, patch)
+}
+
+// DeleteBook moves the books matching the filters to the trash. Not knowing which books matched, it drops every book listing,
+// and the collection listings whose members may have left.
+func (c *CachingHandler) DeleteBook(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	defer c.invalidate(apis.CollectionType)
+	defer c.invalidate(apis.BookType)
+	return c.handler.DeleteBook(ctx, filters, version)
+}
+
+// RestoreBook moves a book out of the trash, back among the members of its collections, whose listings are dropped as well
+func (c *CachingHandler) RestoreBook(ctx context.Context, isbn string) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	defer c.invalidate(apis.BookType, isbn)
+	return c.handler.RestoreBook(ctx, isbn)
+}
+
+// CreateBooks stores the books of the batch and drops the listings that may include any of them
+func (c *CachingHandler) CreateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	defer c.invalidate(apis.BookType, bookIsbns(books)...)
+	return c.handler.CreateBooks(ctx, books, mode)
+}
+
+// UpdateBooks replaces the books of the batch and drops the listings that may include any of them
+func (c *CachingHandler) UpdateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	defer c.invalidate(apis.BookType, bookIsbns(books)...)
+	return c.handler.UpdateBooks(ctx, books, mode)
+}
+
+// bookIsbns returns the ISBNs of the books
+func bookIsbns(books []apis.Book) []string {
+	isbns := make([]string, len(books))
+	for i := range books {
+		isbns[i] = books[i].Isbn
+	}
+	return isbns
+}
+
+// CreateCollection stores a new collection and drops the collection listings
+func (c *CachingHandler) CreateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.CreateCollection(ctx, collection)
+}
+
+// UpdateCollection replaces an existing collection and drops the collection listings
+func (c *CachingHandler) UpdateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.UpdateCollection(ctx, collection)
+}
+
+// DeleteCollection moves the collections matching the filters to the trash and drops the collection listings
+func (c *CachingHandler) DeleteCollection(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.DeleteCollection(ctx, filters, version)
+}
+
+// RestoreCollection moves a collection out of the trash and drops the collection listings
+func (c *CachingHandler) RestoreCollection(ctx context.Context, name string) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.RestoreCollection(ctx, name)
+}
+
+// AddBookToCollection adds a member to the collection and drops the collection listings
+func (c *CachingHandler) AddBookToCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.AddBookToCollection(ctx, name, isbn)
+}
+
+// RemoveBookFromCollection removes a member from the collection and drops the collection listings
+func (c *CachingHandler) RemoveBookFromCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	defer c.invalidate(apis.CollectionType)
+	return c.handler.RemoveBookFromCollection(ctx, name, isbn)
+}
+
+// Purge permanently removes the resources moved to the trash before the given time and drops every listing, the ones including the trash among them
+func (c *CachingHandler) Purge(ctx context.Context, before time.Time) (books int64, collections int64, err error) {
+	defer c.invalidate(apis.CollectionType)
+	defer c.invalidate(apis.BookType)
+	return c.handler.Purge(ctx, before)
+}
+
+// History returns the changes of a book or a collection from the wrapped Handler, without caching them
+func (c *CachingHandler) History(ctx context.Context, kind apis.ResourceType, id string) (entries []apis.HistoryEntry, err error) {
+	return c.handler.History(ctx, kind, id)
+}
+
+// Close closes the wrapped Handler
+func (c *CachingHandler) Close() error {
+	return c.handler.Close()
+}
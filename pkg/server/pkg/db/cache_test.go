@@ -0,0 +1,138 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingHandler(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachingHandler(NewMemoryHandler(), 2, time.Minute)
+
+	for _, book := range []apis.Book{{Isbn: "1", Author: "Dante"}, {Isbn: "2", Author: "Dante"}} {
+		_, err := cache.CreateBook(ctx, &book)
+		require.Nil(t, err)
+	}
+
+	getBooks := func(filter string) *apis.BookPage {
+		page, err := cache.GetBook(ctx, parseFilters(t, apis.BookType, filter), apis.ListOptions{})
+		require.Nil(t, err)
+		return page
+	}
+
+	// the ISBN is compared by value
+	require.Len(t, getBooks("isbn_eq_1").Items, 1)
+	require.Len(t, getBooks("isbn_eq_01").Items, 1)
+	require.Len(t, getBooks("isbn_eq_2").Items, 1)
+	require.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 2}, cache.Stats())
+
+	// writing a book keeps the listings of the other ones
+	_, err := cache.UpdateBook(ctx, &apis.Book{Isbn: "2", Author: "Petrarca"})
+	require.Nil(t, err)
+	require.Equal(t, 1, cache.Stats().Entries)
+	require.Equal(t, "Petrarca", getBooks("isbn_eq_2").Items[0].Author)
+
+	// the filters are compared in their canonical form, and the least recently used listing is evicted
+	require.Len(t, getBooks("author_eq_dante_and_title_ne_none").Items, 1)
+	require.Len(t, getBooks("title_ne_none_and_author_eq_dante").Items, 1)
+	require.Equal(t, CacheStats{Hits: 2, Misses: 4, Evictions: 1, Entries: 2}, cache.Stats())
+
+	// any book write may change the other listings
+	_, err = cache.CreateBook(ctx, &apis.Book{Isbn: "3", Author: "Dante"})
+	require.Nil(t, err)
+	require.Len(t, getBooks("author_eq_dante").Items, 2)
+
+	// the listings expire after the TTL
+	cache.now = func() time.Time { return time.Now().Add(time.Hour) }
+	require.Len(t, getBooks("author_eq_dante").Items, 2)
+	require.Equal(t, int64(2), cache.Stats().Hits)
+}
+
+func TestCachingHandlerCollections(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachingHandler(NewMemoryHandler(), 10, 0)
+
+	_, err := cache.CreateBook(ctx, &apis.Book{Isbn: "1"})
+	require.Nil(t, err)
+	_, err = cache.CreateCollection(ctx, &apis.Collection{Name: "poems", Books: []string{"1"}})
+	require.Nil(t, err)
+
+	getMembers := func() []string {
+		page, err := cache.GetCollection(ctx, parseFilters(t, apis.CollectionType, "name_eq_poems"), apis.ListOptions{})
+		require.Nil(t, err)
+		return page.Items[0].Books
+	}
+
+	require.Equal(t, []string{"1"}, getMembers())
+	require.Equal(t, []string{"1"}, getMembers())
+	require.Equal(t, int64(1), cache.Stats().Hits)
+
+	// the members leave with the books moved to the trash and come back with them
+	_, err = cache.DeleteBook(ctx, parseFilters(t, apis.BookType, "isbn_eq_1"), 0)
+	require.Nil(t, err)
+	require.Empty(t, getMembers())
+
+	_, err = cache.RestoreBook(ctx, "1")
+	require.Nil(t, err)
+	require.Equal(t, []string{"1"}, getMembers())
+
+	_, err = cache.RemoveBookFromCollection(ctx, "poems", "1")
+	require.Nil(t, err)
+	require.Empty(t, getMembers())
+
+	// the listings as of a past time are not cached
+	_, err = cache.GetCollection(ctx, parseFilters(t, apis.CollectionType, "name_eq_poems"), apis.ListOptions{AsOf: time.Now()})
+	require.Nil(t, err)
+	require.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestCachingHandlerReadsOwnWrites(t *testing.T) {
+	primary, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "primary.db")})
+	require.Nil(t, err)
+	defer primary.db.Close()
+
+	// the replica never receives the writes, as if it lagged behind forever
+	lagging, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "replica.db")})
+	require.Nil(t, err)
+	defer lagging.db.Close()
+
+	now := time.Now()
+	primary.replicas = newReplicaSet([]*replica{{db: lagging.db, addr: "replica"}}, time.Minute)
+	primary.replicas.now = func() time.Time { return now }
+	cache := NewCachingHandler(primary, 10, 0)
+
+	alice := WithClient(context.Background(), "alice")
+	bob := WithClient(context.Background(), "bob")
+
+	total := func(ctx context.Context) int64 {
+		page, err := cache.GetBook(ctx, allBooks(t), apis.ListOptions{})
+		require.Nil(t, err)
+		return page.Total
+	}
+
+	_, err = cache.CreateBook(alice, &apis.Book{Isbn: "1"})
+	require.Nil(t, err)
+
+	// bob caches the listing read from the replica, which alice does not see until her window is over
+	require.Equal(t, int64(0), total(bob))
+	require.Equal(t, int64(1), total(alice))
+	require.Equal(t, int64(1), total(alice))
+	require.Equal(t, int64(0), total(bob))
+	require.Equal(t, CacheStats{Hits: 1, Misses: 1, Entries: 1}, cache.Stats())
+
+	now = now.Add(time.Minute)
+	require.Equal(t, int64(0), total(alice))
+	require.Equal(t, int64(2), cache.Stats().Hits)
+}
+
+// parseFilters parses the filters of the resources of the type
+func parseFilters(t *testing.T, kind apis.ResourceType, filters string) *apis.FilterChain {
+	chain, err := apis.ParseResourceFilters(kind, filters)
+	require.Nil(t, err)
+	return chain
+}
@@ -0,0 +1,287 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/iancoleman/strcase"
+)
+
+// bookTable maps apis.Book to the books table
+var bookTable = newTableMapping(apis.BookType, "books", apis.Book{}, "isbn", "version", "deleted_at")
+
+// collectionTable maps apis.Collection to the collections table. The members are stored separately in the collection_members table.
+var collectionTable = newTableMapping(apis.CollectionType, "collections", apis.Collection{}, "name", "version", "deleted_at")
+
+// tableMapping maps the fields of a resource struct to the columns of a table.
+// Every field tagged with `db:"column"` is mapped, fields tagged with `db:"-"` or without tag are ignored.
+type tableMapping struct {
+	kind     apis.ResourceType
+	resource reflect.Type
+	table    string
+	key      string
+	keyField string
+	version  string
+	deleted  string
+	columns  []string
+	fields   []int
+}
+
+// newTableMapping builds the mapping of the resource struct. key is the column of the primary key,
+// version the column incremented on every update for optimistic concurrency control and deleted the column holding when a row was moved to the trash.
+func newTableMapping(kind apis.ResourceType, table string, resource interface{}, key string, version string, deleted string) *tableMapping {
+	t := reflect.TypeOf(resource)
+	mapping := &tableMapping{
+		kind:     kind,
+		resource: t,
+		table:    table,
+		key:      key,
+		version:  version,
+		deleted:  deleted,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		column := t.Field(i).Tag.Get("db")
+		if column == "" || column == "-" {
+			continue
+		}
+		mapping.columns = append(mapping.columns, column)
+		mapping.fields = append(mapping.fields, i)
+
+		if column == key {
+			mapping.keyField = t.Field(i).Name
+		}
+	}
+
+	return mapping
+}
+
+// columnType is how the values of a column are compared and ordered by the database
+type columnType int
+
+const (
+	textColumn columnType = iota
+	integerColumn
+	dateColumn
+	timeColumn
+)
+
+// columnType returns the type of the i-th mapped column
+func (m *tableMapping) columnType(i int) columnType {
+	field := m.resource.Field(m.fields[i])
+	switch {
+	case field.Type == reflect.TypeOf(apis.Date{}):
+		return dateColumn
+	case field.Type.Kind() == reflect.Ptr:
+		return timeColumn
+	// ISBNs are strings in the API, but numbers in the database
+	case field.Type.Kind() != reflect.String, m.columns[i] == "isbn":
+		return integerColumn
+	}
+	return textColumn
+}
+
+// newResource returns a pointer to a new zero resource of the mapped type
+func (m *tableMapping) newResource() interface{} {
+	return reflect.New(m.resource).Interface()
+}
+
+// keyOf returns the primary key of the resource pointer
+func (m *tableMapping) keyOf(resource interface{}) string {
+	return reflect.ValueOf(resource).Elem().FieldByName(m.keyField).String()
+}
+
+// selectList returns the comma separated list of the mapped columns
+func (m *tableMapping) selectList() string {
+	return strings.Join(m.columns, ", ")
+}
+
+// selectStatement returns the query reading all the mapped columns of the rows matching the where clause
+func (m *tableMapping) selectStatement(where string) string {
+	return fmt.Sprintf("SELECT %s FROM %s WHERE %s", m.selectList(), m.table, where)
+}
+
+// visible restricts the where clause to the rows selected depending on whether they are in the trash
+func (m *tableMapping) visible(where string, deleted apis.Deleted) string {
+	switch deleted {
+	case apis.IncludeDeleted:
+		return where
+	case apis.OnlyDeleted:
+		return fmt.Sprintf("(%s) AND %s IS NOT NULL", where, m.deleted)
+	default:
+		return fmt.Sprintf("(%s) AND %s IS NULL", where, m.deleted)
+	}
+}
+
+// orderBy returns the ORDER BY clause for the sort fields. The primary key is always the last criterion to get a stable order.
+func (m *tableMapping) orderBy(sort []apis.SortField) string {
+	var criteria []string
+	key := m.key
+
+	for _, f := range sort {
+		column := strcase.ToSnake(f.Field)
+		if f.Descending {
+			column += " DESC"
+		}
+
+		// the primary key is unique: any following criterion is useless
+		if f.Field == m.keyField {
+			key = column
+			break
+		}
+		criteria = append(criteria, column)
+	}
+
+	return "ORDER BY " + strings.Join(append(criteria, key), ", ")
+}
+
+// insertStatement returns the statement inserting a row. Values must be bound with insertValues.
+func (m *tableMapping) insertStatement(d apis.Dialect) string {
+	return m.insertRowsStatement(d, 1)
+}
+
+// insertRowsStatement returns the statement inserting the given number of rows at once. Values must be bound with the insertValues of each row, one after the other.
+func (m *tableMapping) insertRowsStatement(d apis.Dialect, rows int) string {
+	tuples := make([]string, rows)
+	for i := range tuples {
+		tuples[i] = "(" + apis.Placeholders(d, i*len(m.columns), len(m.columns)) + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", m.table, m.selectList(), strings.Join(tuples, ", "))
+}
+
+// insertValues returns the values to bind to the insert statement. New rows always start from the first version and out of the trash.
+func (m *tableMapping) insertValues(resource interface{}) []interface{} {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+
+	values := make([]interface{}, len(m.fields))
+	for i, field := range m.fields {
+		switch m.columns[i] {
+		case m.version:
+			values[i] = 1
+		case m.deleted:
+			values[i] = nil
+		default:
+			values[i] = v.Field(field).Interface()
+		}
+	}
+	return values
+}
+
+// updateStatement returns the statement updating every column of the row identified by the primary key and incrementing its version.
+// Rows in the trash are not updated. If checkVersion is true, the row is only updated if its version matches. Values must be bound with updateValues.
+func (m *tableMapping) updateStatement(d apis.Dialect, checkVersion bool) string {
+	var set []string
+	for _, column := range m.columns {
+		if column == m.key || column == m.version || column == m.deleted {
+			continue
+		}
+		set = append(set, column+" = "+d.Placeholder(len(set)+1))
+	}
+
+	if m.version == "" {
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s = %s", m.table, strings.Join(set, ", "), m.key, d.Placeholder(len(set)+1))
+	}
+
+	statement := fmt.Sprintf("UPDATE %s SET %s, %s = %s + 1 WHERE %s = %s", m.table, strings.Join(set, ", "), m.version, m.version, m.key, d.Placeholder(len(set)+1))
+	if checkVersion {
+		statement += fmt.Sprintf(" AND %s = %s", m.version, d.Placeholder(len(set)+2))
+	}
+	return statement + fmt.Sprintf(" AND %s IS NULL", m.deleted)
+}
+
+// updateValues returns the values to bind to the update statement: all the columns but the key, the version and the deletion time followed by the key.
+// If checkVersion is true, the version of the resource follows the key.
+func (m *tableMapping) updateValues(resource interface{}, checkVersion bool) []interface{} {
+	v := reflect.Indirect(reflect.ValueOf(resource))
+
+	var values []interface{}
+	var key, version interface{}
+	for i, field := range m.fields {
+		switch m.columns[i] {
+		case m.key:
+			key = v.Field(field).Interface()
+		case m.version:
+			version = v.Field(field).Interface()
+		case m.deleted:
+		default:
+			values = append(values, v.Field(field).Interface())
+		}
+	}
+
+	values = append(values, key)
+	if checkVersion {
+		values = append(values, version)
+	}
+	return values
+}
+
+// scanTargets returns the destinations to pass to Scan when reading the columns of selectList into the resource pointer
+func (m *tableMapping) scanTargets(resource interface{}) []interface{} {
+	v := reflect.ValueOf(resource).Elem()
+
+	targets := make([]interface{}, len(m.fields))
+	for i, field := range m.fields {
+		targets[i] = nullable{v.Field(field)}
+	}
+	return targets
+}
+
+// nullable scans a column into a struct field turning NULL into the zero value of the field
+type nullable struct {
+	field reflect.Value
+}
+
+func (n nullable) Scan(src interface{}) error {
+	if src == nil {
+		n.field.Set(reflect.Zero(n.field.Type()))
+		return nil
+	}
+
+	if scanner, ok := n.field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	if n.field.Type() == reflect.TypeOf(&time.Time{}) {
+		var t sql.NullTime
+		if err := t.Scan(src); err != nil {
+			return err
+		}
+		utc := t.Time.UTC()
+		n.field.Set(reflect.ValueOf(&utc))
+		return nil
+	}
+
+	switch n.field.Kind() {
+	case reflect.String:
+		var s sql.NullString
+		if err := s.Scan(src); err != nil {
+			return err
+		}
+		n.field.SetString(s.String)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var i sql.NullInt64
+		if err := i.Scan(src); err != nil {
+			return err
+		}
+		if i.Int64 < 0 || n.field.OverflowUint(uint64(i.Int64)) {
+			return fmt.Errorf("value %v overflows %v", i.Int64, n.field.Type())
+		}
+		n.field.SetUint(uint64(i.Int64))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i sql.NullInt64
+		if err := i.Scan(src); err != nil {
+			return err
+		}
+		if n.field.OverflowInt(i.Int64) {
+			return fmt.Errorf("value %v overflows %v", i.Int64, n.field.Type())
+		}
+		n.field.SetInt(i.Int64)
+	default:
+		return fmt.Errorf("unsupported field type %v", n.field.Type())
+	}
+	return nil
+}
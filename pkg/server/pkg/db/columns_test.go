@@ -0,0 +1,53 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableMapping(t *testing.T) {
+	require.Equal(t, "SELECT title, author, isbn, published_date, edition, description, genre, version, deleted_at FROM books WHERE isbn = ?", bookTable.selectStatement("isbn = ?"))
+	require.Equal(t, "INSERT INTO books (title, author, isbn, published_date, edition, description, genre, version, deleted_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)", bookTable.insertStatement(apis.Postgres))
+	require.Equal(t, "INSERT INTO books (title, author, isbn, published_date, edition, description, genre, version, deleted_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9), ($10, $11, $12, $13, $14, $15, $16, $17, $18)", bookTable.insertRowsStatement(apis.Postgres, 2))
+	require.Equal(t, "UPDATE books SET title = $1, author = $2, published_date = $3, edition = $4, description = $5, genre = $6, version = version + 1 WHERE isbn = $7 AND deleted_at IS NULL", bookTable.updateStatement(apis.Postgres, false))
+	require.Equal(t, "UPDATE books SET title = $1, author = $2, published_date = $3, edition = $4, description = $5, genre = $6, version = version + 1 WHERE isbn = $7 AND version = $8 AND deleted_at IS NULL", bookTable.updateStatement(apis.Postgres, true))
+
+	book := apis.Book{Title: "Romeo and Juliet", Isbn: "1234567890987", Edition: 2, Version: 5}
+	require.Equal(t, []interface{}{"Romeo and Juliet", "", "1234567890987", apis.Date{}, uint8(2), "", "", 1, nil}, bookTable.insertValues(&book))
+	require.Equal(t, []interface{}{"Romeo and Juliet", "", apis.Date{}, uint8(2), "", "", "1234567890987"}, bookTable.updateValues(&book, false))
+	require.Equal(t, []interface{}{"Romeo and Juliet", "", apis.Date{}, uint8(2), "", "", "1234567890987", uint64(5)}, bookTable.updateValues(&book, true))
+
+	scanned := apis.Book{Title: "to be cleared"}
+	for i, target := range bookTable.scanTargets(&scanned) {
+		var src interface{}
+		switch bookTable.columns[i] {
+		case "isbn":
+			src = int64(1234567890987)
+		case "edition":
+			src = int64(3)
+		case "published_date":
+			src = "2000-01-02"
+		case "deleted_at":
+			src = time.Date(2020, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+		}
+		require.Nil(t, target.(nullable).Scan(src))
+	}
+	require.Equal(t, "", scanned.Title)
+	require.Equal(t, "1234567890987", scanned.Isbn)
+	require.Equal(t, uint8(3), scanned.Edition)
+	require.Equal(t, "2000-01-02", scanned.PublishedDate.String())
+	require.Equal(t, time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC), *scanned.DeletedAt)
+}
+
+// TestColumnsMatchFilters ensures the mapped columns are the ones generated by the filters
+func TestColumnsMatchFilters(t *testing.T) {
+	typ := reflect.TypeOf(apis.Book{})
+	for i, field := range bookTable.fields {
+		require.Equal(t, strcase.ToSnake(typ.Field(field).Name), bookTable.columns[i])
+	}
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Errors returned by the Handler implementations. They are wrapped together with the details of the failure, use errors.Is to check them.
+var (
+	// ErrNotFound is returned when the resource to modify does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the resource clashes with an existing one, e.g. a duplicate ISBN
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is returned when the resource to modify is not at the expected version
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrAmbiguous is returned when a conditional operation, which applies to a single resource, matches several of them
+	ErrAmbiguous = errors.New("ambiguous")
+	// ErrValidation is returned when the resource is not acceptable for the database
+	ErrValidation = errors.New("validation failed")
+	// ErrUnavailable is returned when the database cannot be reached
+	ErrUnavailable = errors.New("database unavailable")
+	// ErrAborted is returned for the items of an atomic batch that were not applied because other items failed
+	ErrAborted = errors.New("not applied")
+	// ErrInternal is returned for the unexpected failures, whose details are logged rather than returned
+	ErrInternal = errors.New("internal error")
+)
+
+var isbnFormat = regexp.MustCompile(`^[0-9]{1,13}$`)
+
+// validateBook checks the book fields that the database cannot store
+func validateBook(book *apis.Book) error {
+	if !isbnFormat.MatchString(book.Isbn) {
+		return fmt.Errorf("%w: isbn must be made of up to 13 digits", ErrValidation)
+	}
+	return nil
+}
+
+// validateCollection checks the collection fields that the database cannot store
+func validateCollection(collection *apis.Collection) error {
+	if collection.Name == "" {
+		return fmt.Errorf("%w: collection name cannot be empty", ErrValidation)
+	}
+
+	listed := make(map[string]bool, len(collection.Books))
+	for _, isbn := range collection.Books {
+		if !isbnFormat.MatchString(isbn) {
+			return fmt.Errorf("%w: isbn %v must be made of up to 13 digits", ErrValidation, isbn)
+		}
+		if listed[isbn] {
+			return fmt.Errorf("%w: book %v is listed more than once", ErrValidation, isbn)
+		}
+		listed[isbn] = true
+	}
+	return nil
+}
+
+// classifyFunc maps a driver error to one of the Handler errors. It returns nil if the error is not known.
+type classifyFunc func(err error) error
+
+// classifyConnError recognizes the connection failures common to every driver
+func classifyConnError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// classifyMySQLError maps the MySQL server error numbers
+func classifyMySQLError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		if errors.Is(err, mysql.ErrInvalidConn) {
+			return ErrUnavailable
+		}
+		return classifyConnError(err)
+	}
+
+	switch mysqlErr.Number {
+	// duplicate entry
+	case 1062:
+		return ErrConflict
+	// column cannot be null, data too long, out of range, incorrect value, foreign key
+	case 1048, 1406, 1264, 1292, 1366, 1451, 1452:
+		return ErrValidation
+	// too many connections, server shutdown, read only
+	case 1040, 1053, 1290:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// classifyPostgresError maps the PostgreSQL SQLSTATE codes
+func classifyPostgresError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return classifyConnError(err)
+	}
+
+	switch {
+	case pqErr.Code == "23505":
+		return ErrConflict
+	// data exceptions and integrity violations other than unique
+	case pqErr.Code.Class() == "22", pqErr.Code.Class() == "23":
+		return ErrValidation
+	// connection exceptions, insufficient resources, operator intervention
+	case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53", pqErr.Code.Class() == "57":
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// classifySQLiteError maps the SQLite extended result codes
+func classifySQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return classifyConnError(err)
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		return ErrConflict
+	case sqlite3.SQLITE_CONSTRAINT_CHECK, sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY, sqlite3.SQLITE_MISMATCH, sqlite3.SQLITE_TOOBIG:
+		return ErrValidation
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// driverMessage strips the driver prefix from an error message
+func driverMessage(err error) string {
+	msg := err.Error()
+	for _, prefix := range []string{"pq: ", "constraint failed: "} {
+		msg = strings.TrimPrefix(msg, prefix)
+	}
+	return msg
+}
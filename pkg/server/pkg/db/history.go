@@ -0,0 +1,497 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// anonymous is the principal recorded when the context does not name one
+const anonymous = "anonymous"
+
+// principalKey is the context key of the principal performing the changes
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context naming the principal recorded in the history of the changes made with it
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// principalFrom returns the principal named by the context
+func principalFrom(ctx context.Context) string {
+	if principal, ok := ctx.Value(principalKey{}).(string); ok && principal != "" {
+		return principal
+	}
+	return anonymous
+}
+
+// historyID normalizes the identifier of a resource, so that its history is found whatever the form of the identifier as the databases compare them
+func historyID(kind apis.ResourceType, id string) string {
+	if kind == apis.CollectionType {
+		return strings.ToLower(id)
+	}
+	return isbnKey(id)
+}
+
+// historyEntry builds the entry recording a change of a resource between the two JSON states, together with the changed fields
+func historyEntry(kind apis.ResourceType, id string, op apis.HistoryOperation, principal string, at time.Time, before, after json.RawMessage) (apis.HistoryEntry, error) {
+	changes, err := apis.Diff(before, after)
+	if err != nil {
+		return apis.HistoryEntry{}, fmt.Errorf("diff %v %v: %v", kind, id, err)
+	}
+
+	return apis.HistoryEntry{
+		Resource:  kind,
+		ID:        id,
+		Operation: op,
+		Principal: principal,
+		ChangedAt: at,
+		Before:    before,
+		After:     after,
+		Changes:   changes,
+	}, nil
+}
+
+// marshalState returns the JSON state of a resource recorded in the history, nil if the resource does not exist
+func marshalState(resource interface{}) (json.RawMessage, error) {
+	if resource == nil {
+		return nil, nil
+	}
+	return json.Marshal(resource)
+}
+
+// queryer runs queries either directly on a database, the primary or a replica, or within a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// readRows reads the resources of the rows matching the where clause, collections together with their members, and returns pointers to them
+func (s *sqlHandler) readRows(ctx context.Context, q queryer, m *tableMapping, where string, args ...interface{}) ([]interface{}, error) {
+	rows, err := q.QueryContext(ctx, m.selectStatement(where), args...)
+	if err != nil {
+		return nil, s.queryError(ctx, "execute statement", err)
+	}
+
+	var resources []interface{}
+	for rows.Next() {
+		resource := m.newResource()
+		if err = rows.Scan(m.scanTargets(resource)...); err != nil {
+			rows.Close()
+			return nil, s.queryError(ctx, "scan row", err)
+		}
+		resources = append(resources, resource)
+	}
+
+	// the rows must be closed before the members are read within the same transaction
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, s.queryError(ctx, "read rows", err)
+	}
+
+	if m == collectionTable {
+		collections := make([]*apis.Collection, len(resources))
+		for i := range resources {
+			collections[i] = resources[i].(*apis.Collection)
+			collections[i].Books = []string{}
+		}
+
+		if err = s.readMembers(ctx, q, collections); err != nil {
+			return nil, err
+		}
+	}
+
+	return resources, nil
+}
+
+// readRow reads the resource identified by key, selecting the rows in the trash as requested. It returns nil if there is no such row.
+func (s *sqlHandler) readRow(ctx context.Context, q queryer, m *tableMapping, key string, deleted apis.Deleted) (interface{}, error) {
+	resources, err := s.readRows(ctx, q, m, m.visible(m.key+" = "+s.dialect.Placeholder(1), deleted), key)
+	if err != nil || len(resources) == 0 {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// readKeys reads the resources identified by the keys, selecting the rows in the trash as requested, and returns them indexed by historyID
+func (s *sqlHandler) readKeys(ctx context.Context, q queryer, m *tableMapping, keys []string, deleted apis.Deleted) (map[string]interface{}, error) {
+	resources := make(map[string]interface{}, len(keys))
+
+	for start := 0; start < len(keys); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		args := make([]interface{}, 0, end-start)
+		for _, key := range keys[start:end] {
+			args = append(args, key)
+		}
+
+		chunk, err := s.readRows(ctx, q, m, m.visible(fmt.Sprintf("%s IN (%s)", m.key, apis.Placeholders(s.dialect, 0, len(args))), deleted), args...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range chunk {
+			resources[historyID(m.kind, m.keyOf(resource))] = resource
+		}
+	}
+
+	return resources, nil
+}
+
+// record writes to the history the change of the resource identified by key between the two states, nil meaning that the resource did not exist.
+// It must run in the transaction of the change, so that the entry is only kept if the change is.
+func (s *sqlHandler) record(ctx context.Context, tx *sql.Tx, m *tableMapping, op apis.HistoryOperation, key string, before interface{}, after interface{}) error {
+	var states [2]interface{}
+	for i, resource := range []interface{}{before, after} {
+		state, err := marshalState(resource)
+		if err != nil {
+			return fmt.Errorf("marshal %v %v: %v", m.kind, key, err)
+		}
+		if state != nil {
+			states[i] = string(state)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO history (resource_type, resource_id, operation, principal, changed_at, before_state, after_state) VALUES (%s)", apis.Placeholders(s.dialect, 0, 7)),
+		m.kind.String(), historyID(m.kind, key), string(op), principalFrom(ctx), time.Now().UTC(), states[0], states[1])
+	if err != nil {
+		return s.queryError(ctx, "record history", err)
+	}
+	return nil
+}
+
+// recordChange reads the current state of the row identified by key, which is returned, and records its change from the before state
+func (s *sqlHandler) recordChange(ctx context.Context, tx *sql.Tx, m *tableMapping, op apis.HistoryOperation, key string, before interface{}) (after interface{}, err error) {
+	if after, err = s.readRow(ctx, tx, m, key, apis.IncludeDeleted); err != nil {
+		return nil, err
+	}
+
+	if after == nil {
+		return nil, fmt.Errorf("%w: %v %v disappeared while recording its history", ErrConflict, m.kind, key)
+	}
+
+	if err = s.record(ctx, tx, m, op, key, before, after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// History returns the changes of the resource in the order they happened, read from a replica if any. It returns ErrNotFound if the resource has never been changed.
+func (s *sqlHandler) History(ctx context.Context, kind apis.ResourceType, id string) (entries []apis.HistoryEntry, err error) {
+	if kind == apis.BookType {
+		if err = validateBook(&apis.Book{Isbn: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err = s.read(ctx, func(q queryer) (err error) {
+		entries, err = s.readHistory(ctx, q, kind, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %v %v has no history", ErrNotFound, kind, id)
+	}
+	return entries, nil
+}
+
+// readHistory reads the changes of the resource with q
+func (s *sqlHandler) readHistory(ctx context.Context, q queryer, kind apis.ResourceType, id string) (entries []apis.HistoryEntry, err error) {
+	qs := fmt.Sprintf("SELECT resource_id, operation, principal, changed_at, before_state, after_state FROM history WHERE resource_type = %s AND resource_id = %s ORDER BY id",
+		s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+
+	rows, err := q.QueryContext(ctx, qs, kind.String(), historyID(kind, id))
+	if err != nil {
+		return nil, s.queryError(ctx, "execute statement", err)
+	}
+	defer rows.Close()
+
+	entries = []apis.HistoryEntry{}
+	for rows.Next() {
+		var key, op, principal string
+		var at time.Time
+		var before, after sql.NullString
+		if err = rows.Scan(&key, &op, &principal, &at, &before, &after); err != nil {
+			return nil, s.queryError(ctx, "scan row", err)
+		}
+
+		entry, err := historyEntry(kind, key, apis.HistoryOperation(op), principal, at.UTC(), nullJSON(before), nullJSON(after))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, s.queryError(ctx, "read rows", err)
+	}
+	return entries, nil
+}
+
+// nullJSON converts a nullable JSON column, NULL being a missing state
+func nullJSON(s sql.NullString) json.RawMessage {
+	if !s.Valid {
+		return nil
+	}
+	return json.RawMessage(s.String)
+}
+
+// stateColumnFunc returns the expression reading the field of the after_state JSON column named after the table column,
+// so that the filters and the ordering apply to the past states as they do to the table
+type stateColumnFunc func(column string, t columnType) string
+
+// mysqlStateColumn casts the text fields to the collation of the connection, case-insensitive as the tables
+func mysqlStateColumn(column string, t columnType) string {
+	switch t {
+	case integerColumn:
+		return fmt.Sprintf("JSON_VALUE(after_state, '$.%s' RETURNING UNSIGNED)", column)
+	case dateColumn:
+		return fmt.Sprintf("JSON_VALUE(after_state, '$.%s' RETURNING DATE)", column)
+	case timeColumn:
+		return fmt.Sprintf("JSON_VALUE(after_state, '$.%s')", column)
+	}
+	return fmt.Sprintf("CAST(JSON_VALUE(after_state, '$.%s') AS CHAR)", column)
+}
+
+func postgresStateColumn(column string, t columnType) string {
+	switch t {
+	case integerColumn:
+		return fmt.Sprintf("(after_state::json ->> '%s')::bigint", column)
+	case dateColumn:
+		return fmt.Sprintf("(after_state::json ->> '%s')::date", column)
+	case timeColumn:
+		return fmt.Sprintf("(after_state::json ->> '%s')::timestamp", column)
+	}
+	return fmt.Sprintf("(after_state::json ->> '%s')::citext", column)
+}
+
+// sqliteStateColumn keeps the dates and the times as text, as SQLite stores them
+func sqliteStateColumn(column string, t columnType) string {
+	switch t {
+	case integerColumn:
+		return fmt.Sprintf("CAST(json_extract(after_state, '$.%s') AS INTEGER)", column)
+	case dateColumn, timeColumn:
+		return fmt.Sprintf("json_extract(after_state, '$.%s')", column)
+	}
+	return fmt.Sprintf("json_extract(after_state, '$.%s') COLLATE NOCASE", column)
+}
+
+// pastTable returns the derived table holding the states of the resources of the table at the given time, that is the after state of the latest change
+// of each resource up to then, together with the arguments of its placeholders. The resources that did not exist at that time are left out.
+// Its columns are the ones of the table plus after_state.
+func (s *sqlHandler) pastTable(m *tableMapping, at time.Time) (from string, args []interface{}) {
+	columns := make([]string, len(m.columns))
+	for i, column := range m.columns {
+		columns[i] = s.stateColumn(column, m.columnType(i)) + " AS " + column
+	}
+
+	from = fmt.Sprintf("(SELECT %s, after_state FROM history WHERE id IN (SELECT MAX(id) FROM history WHERE resource_type = %s AND changed_at <= %s GROUP BY resource_id) AND after_state IS NOT NULL) past",
+		strings.Join(columns, ", "), s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+	return from, []interface{}{m.kind.String(), at.UTC()}
+}
+
+// getBookAsOf reads with q the page of the books as they were at opts.AsOf
+func (s *sqlHandler) getBookAsOf(ctx context.Context, q queryer, filters *apis.FilterChain, opts apis.ListOptions) (*apis.BookPage, error) {
+	page := &apis.BookPage{Items: []apis.Book{}}
+
+	from, args := s.pastTable(bookTable, opts.AsOf)
+	total, more, err := s.listFrom(ctx, q, bookTable, from, "after_state", args, filters, opts, func(rows *sql.Rows) error {
+		var state string
+		if err := rows.Scan(&state); err != nil {
+			return err
+		}
+
+		book := apis.Book{}
+		if err := json.Unmarshal([]byte(state), &book); err != nil {
+			return fmt.Errorf("decode book history: %v", err)
+		}
+		page.Items = append(page.Items, book)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	page.Total = total
+	if more && opts.KeysetOrdered(bookTable.keyField) {
+		page.NextCursor = page.Items[len(page.Items)-1].Isbn
+	}
+	return page, nil
+}
+
+// getCollectionAsOf reads with q the page of the collections as they were at opts.AsOf.
+// As for the live collections, the members are the books that existed out of the trash at the same time.
+func (s *sqlHandler) getCollectionAsOf(ctx context.Context, q queryer, filters *apis.FilterChain, opts apis.ListOptions) (*apis.CollectionPage, error) {
+	page := &apis.CollectionPage{Items: []apis.Collection{}}
+
+	from, args := s.pastTable(collectionTable, opts.AsOf)
+	total, more, err := s.listFrom(ctx, q, collectionTable, from, "after_state", args, filters, opts, func(rows *sql.Rows) error {
+		var state string
+		if err := rows.Scan(&state); err != nil {
+			return err
+		}
+
+		collection := apis.Collection{}
+		if err := json.Unmarshal([]byte(state), &collection); err != nil {
+			return fmt.Errorf("decode collection history: %v", err)
+		}
+		page.Items = append(page.Items, collection)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var isbns []string
+	for _, collection := range page.Items {
+		isbns = append(isbns, collection.Books...)
+	}
+
+	live, err := s.liveBooksAsOf(ctx, q, isbns, opts.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range page.Items {
+		members := []string{}
+		for _, isbn := range page.Items[i].Books {
+			if live[isbnKey(isbn)] {
+				members = append(members, isbn)
+			}
+		}
+		page.Items[i].Books = members
+	}
+
+	page.Total = total
+	if more && opts.KeysetOrdered(collectionTable.keyField) {
+		page.NextCursor = page.Items[len(page.Items)-1].Name
+	}
+	return page, nil
+}
+
+// liveBooksAsOf reads with q which of the books existed out of the trash at the given time, indexed by isbnKey
+func (s *sqlHandler) liveBooksAsOf(ctx context.Context, q queryer, isbns []string, at time.Time) (map[string]bool, error) {
+	live := make(map[string]bool, len(isbns))
+	from, args := s.pastTable(bookTable, at)
+
+	for start := 0; start < len(isbns); start += batchChunkSize {
+		end := start + batchChunkSize
+		if end > len(isbns) {
+			end = len(isbns)
+		}
+
+		query := append([]interface{}{}, args...)
+		for _, isbn := range isbns[start:end] {
+			query = append(query, isbn)
+		}
+
+		where := bookTable.visible(fmt.Sprintf("isbn IN (%s)", apis.Placeholders(s.dialect, len(args), end-start)), apis.ExcludeDeleted)
+		rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT isbn FROM %s WHERE %s", from, where), query...)
+		if err != nil {
+			return nil, s.queryError(ctx, "execute statement", err)
+		}
+
+		for rows.Next() {
+			var isbn string
+			if err = rows.Scan(&isbn); err != nil {
+				rows.Close()
+				return nil, s.queryError(ctx, "scan row", err)
+			}
+			live[isbnKey(isbn)] = true
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, s.queryError(ctx, "read rows", err)
+		}
+	}
+
+	return live, nil
+}
+
+// statesAsOf returns the states of the resources of the kind at the given time, that is the after state of the latest change of each resource up to then
+func (m *MemoryHandler) statesAsOf(kind apis.ResourceType, at time.Time) []json.RawMessage {
+	latest := make(map[string]json.RawMessage)
+	for _, entry := range m.history {
+		if entry.Resource == kind && !entry.ChangedAt.After(at) {
+			latest[entry.ID] = entry.After
+		}
+	}
+
+	var states []json.RawMessage
+	for _, state := range latest {
+		if state != nil {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// booksAsOf returns the page of the books, decoded from their states, matching the filters and the options
+func booksAsOf(states []json.RawMessage, filters *apis.FilterChain, opts apis.ListOptions) (*apis.BookPage, error) {
+	var books []apis.Book
+	for _, state := range states {
+		book := apis.Book{}
+		if err := json.Unmarshal(state, &book); err != nil {
+			return nil, fmt.Errorf("decode book history: %v", err)
+		}
+
+		if opts.Deleted.Match(book.DeletedAt) && filters.Match(&book) {
+			books = append(books, book)
+		}
+	}
+
+	return paginateBooks(books, opts), nil
+}
+
+// collectionsAsOf returns the page of the collections, decoded from their states, matching the filters and the options.
+// As for the live collections, the members are the books that existed out of the trash at the same time, whose states are bookStates.
+func collectionsAsOf(states []json.RawMessage, bookStates []json.RawMessage, filters *apis.FilterChain, opts apis.ListOptions) (*apis.CollectionPage, error) {
+	live := make(map[string]bool, len(bookStates))
+	for _, state := range bookStates {
+		book := apis.Book{}
+		if err := json.Unmarshal(state, &book); err != nil {
+			return nil, fmt.Errorf("decode book history: %v", err)
+		}
+		live[isbnKey(book.Isbn)] = book.DeletedAt == nil
+	}
+
+	var collections []apis.Collection
+	for _, state := range states {
+		collection := apis.Collection{}
+		if err := json.Unmarshal(state, &collection); err != nil {
+			return nil, fmt.Errorf("decode collection history: %v", err)
+		}
+
+		members := []string{}
+		for _, isbn := range collection.Books {
+			if live[isbnKey(isbn)] {
+				members = append(members, isbn)
+			}
+		}
+		collection.Books = members
+
+		if opts.Deleted.Match(collection.DeletedAt) && filters.Match(&collection) {
+			collections = append(collections, collection)
+		}
+	}
+
+	return paginateCollections(collections, opts), nil
+}
@@ -0,0 +1,812 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryHandler keeps the books and the collections in memory and evaluates the filters directly on them. Data is lost when the server stops.
+type MemoryHandler struct {
+	mu sync.RWMutex
+	// books are indexed by normalized ISBN, since ISBNs are compared by value as in the databases
+	books map[string]apis.Book
+	// collections are indexed by lower case name, since names are compared ignoring case as in the databases
+	collections map[string]apis.Collection
+	history     []apis.HistoryEntry
+}
+
+// isbnKey normalizes an ISBN so that ISBNs are compared by value as the databases do
+func isbnKey(isbn string) string {
+	if key := strings.TrimLeft(isbn, "0"); key != "" {
+		return key
+	}
+	return "0"
+}
+
+// NewMemoryHandler returns a new empty MemoryHandler
+func NewMemoryHandler() *MemoryHandler {
+	return &MemoryHandler{
+		books:       make(map[string]apis.Book),
+		collections: make(map[string]apis.Collection),
+	}
+}
+
+// CreateBook stores a new book
+func (m *MemoryHandler) CreateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateBook(book); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.books[isbnKey(book.Isbn)]; exists {
+		return "", fmt.Errorf("%w: book with ISBN %v already exists", ErrConflict, book.Isbn)
+	}
+	book.Version = 1
+	book.DeletedAt = nil
+	if err = m.record(ctx, apis.BookType, book.Isbn, apis.HistoryCreate, nil, *book); err != nil {
+		return "", err
+	}
+	m.books[isbnKey(book.Isbn)] = *book
+
+	return fmt.Sprintf("Created book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+}
+
+// UpdateBook replaces an existing book, provided it is still at book.Version if set
+func (m *MemoryHandler) UpdateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateBook(book); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.books[isbnKey(book.Isbn)]
+	if !exists || existing.DeletedAt != nil {
+		return "", fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, book.Isbn)
+	}
+	if book.Version != 0 && book.Version != existing.Version {
+		return "", fmt.Errorf("%w: book with ISBN %v is at version %d", ErrPreconditionFailed, book.Isbn, existing.Version)
+	}
+	book.Version = existing.Version + 1
+	book.DeletedAt = nil
+	if err = m.record(ctx, apis.BookType, book.Isbn, apis.HistoryUpdate, existing, *book); err != nil {
+		return "", err
+	}
+	m.books[isbnKey(book.Isbn)] = *book
+
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+}
+
+// PatchBook changes the stored book with patch and replaces it, provided it is at version
+func (m *MemoryHandler) PatchBook(ctx context.Context, isbn string, version uint64, patch func(book *apis.Book) error) (message string, patched *apis.Book, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", nil, err
+	}
+
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.books[isbnKey(isbn)]
+	if !exists || existing.DeletedAt != nil {
+		return "", nil, fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, isbn)
+	}
+
+	book, err := patchBook(existing, version, patch)
+	if err != nil {
+		return "", nil, err
+	}
+	book.Version = existing.Version + 1
+	if err = m.record(ctx, apis.BookType, book.Isbn, apis.HistoryUpdate, existing, book); err != nil {
+		return "", nil, err
+	}
+	m.books[isbnKey(isbn)] = book
+
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), &book, nil
+}
+
+// CreateBooks stores the books of the batch. It returns the outcome of each book, nil meaning created.
+func (m *MemoryHandler) CreateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results = make([]error, len(books))
+	pending := prepareBatch(books, results)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, i := range pending {
+		if _, exists := m.books[isbnKey(books[i].Isbn)]; exists {
+			results[i] = fmt.Errorf("%w: book with ISBN %v already exists", ErrConflict, books[i].Isbn)
+		}
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	mark := len(m.history)
+	for _, i := range pending {
+		if results[i] == nil {
+			books[i].Version = 1
+			books[i].DeletedAt = nil
+			if err = m.record(ctx, apis.BookType, books[i].Isbn, apis.HistoryCreate, nil, books[i]); err != nil {
+				m.history = m.history[:mark]
+				return nil, err
+			}
+		}
+	}
+
+	for _, i := range pending {
+		if results[i] == nil {
+			m.books[isbnKey(books[i].Isbn)] = books[i]
+		}
+	}
+
+	return results, nil
+}
+
+// UpdateBooks replaces the books of the batch. It returns the outcome of each book, nil meaning updated.
+func (m *MemoryHandler) UpdateBooks(ctx context.Context, books []apis.Book, mode apis.BatchMode) (results []error, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results = make([]error, len(books))
+	pending := prepareBatch(books, results)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, i := range pending {
+		if existing, exists := m.books[isbnKey(books[i].Isbn)]; !exists || existing.DeletedAt != nil {
+			results[i] = fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, books[i].Isbn)
+		}
+	}
+
+	if abortBatch(mode, results) {
+		return results, nil
+	}
+
+	mark := len(m.history)
+	for _, i := range pending {
+		if results[i] == nil {
+			existing := m.books[isbnKey(books[i].Isbn)]
+			books[i].Version = existing.Version + 1
+			books[i].DeletedAt = nil
+			if err = m.record(ctx, apis.BookType, books[i].Isbn, apis.HistoryUpdate, existing, books[i]); err != nil {
+				m.history = m.history[:mark]
+				return nil, err
+			}
+		}
+	}
+
+	for _, i := range pending {
+		if results[i] == nil {
+			m.books[isbnKey(books[i].Isbn)] = books[i]
+		}
+	}
+
+	return results, nil
+}
+
+// ApplyBook stores the book, replacing it if it already exists
+func (m *MemoryHandler) ApplyBook(ctx context.Context, book *apis.Book) (message string, created bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	if err = validateBook(book); err != nil {
+		return "", false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.books[isbnKey(book.Isbn)]
+	if existing.DeletedAt != nil {
+		return "", false, fmt.Errorf("%w: book with ISBN %v is in the trash", ErrConflict, book.Isbn)
+	}
+	book.Version = existing.Version + 1
+	book.DeletedAt = nil
+
+	if !exists {
+		if err = m.record(ctx, apis.BookType, book.Isbn, apis.HistoryCreate, nil, *book); err != nil {
+			return "", false, err
+		}
+		m.books[isbnKey(book.Isbn)] = *book
+		return fmt.Sprintf("Created book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), true, nil
+	}
+
+	if err = m.record(ctx, apis.BookType, book.Isbn, apis.HistoryUpdate, existing, *book); err != nil {
+		return "", false, err
+	}
+	m.books[isbnKey(book.Isbn)] = *book
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), false, nil
+}
+
+// GetBook returns a page of the books matching the supplied filters
+func (m *MemoryHandler) GetBook(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.BookPage, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !opts.AsOf.IsZero() {
+		return booksAsOf(m.statesAsOf(apis.BookType, opts.AsOf), filters, opts)
+	}
+
+	var books []apis.Book
+	for _, book := range m.books {
+		if opts.Deleted.Match(book.DeletedAt) && filters.Match(&book) {
+			books = append(books, book)
+		}
+	}
+
+	return paginateBooks(books, opts), nil
+}
+
+// paginateBooks orders the books and returns the page selected by the options
+func paginateBooks(books []apis.Book, opts apis.ListOptions) *apis.BookPage {
+	start, end, next := paginate(books, "Isbn", apis.CompareIdentifiers, opts)
+
+	page := &apis.BookPage{Items: append([]apis.Book{}, books[start:end]...)}
+	page.Total = int64(len(books))
+	page.NextCursor = next
+	return page
+}
+
+// paginate orders the resources, which must be a slice of structs, and returns the bounds of the page selected by the options together with the next cursor.
+// As in the SQL handlers, the key field is always the last criterion and keys are compared with compareKeys.
+func paginate(resources interface{}, key string, compareKeys func(a, b string) int, opts apis.ListOptions) (start, end int, next string) {
+	v := reflect.ValueOf(resources)
+	keyOf := func(i int) string {
+		return v.Index(i).FieldByName(key).String()
+	}
+
+	var criteria []apis.SortField
+	keyDescending := false
+	for _, f := range opts.Sort {
+		if f.Field == key {
+			keyDescending = f.Descending
+			break
+		}
+		criteria = append(criteria, f)
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if cmp := apis.CompareResources(v.Index(i).Interface(), v.Index(j).Interface(), criteria); cmp != 0 {
+			return cmp < 0
+		}
+		cmp := compareKeys(keyOf(i), keyOf(j))
+		if keyDescending {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	if opts.Cursor != "" {
+		start = sort.Search(v.Len(), func(i int) bool {
+			return compareKeys(keyOf(i), opts.Cursor) > 0
+		})
+	}
+
+	start += opts.Offset
+	if start > v.Len() {
+		start = v.Len()
+	}
+
+	end = v.Len()
+	if opts.Limit > 0 && end-start > opts.Limit {
+		end = start + opts.Limit
+		if opts.KeysetOrdered(key) {
+			next = keyOf(end - 1)
+		}
+	}
+
+	return start, end, next
+}
+
+// DeleteBook moves the books matching the supplied filters to the trash. Their memberships are kept, but hidden, until they are restored or purged.
+// A non-zero version requires the filters to match a single book at that version.
+func (m *MemoryHandler) DeleteBook(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var isbns []string
+	for isbn, book := range m.books {
+		if book.DeletedAt == nil && filters.Match(&book) {
+			isbns = append(isbns, isbn)
+		}
+	}
+
+	if err = checkDeleteVersion(apis.BookType, isbns, version, func(isbn string) uint64 { return m.books[isbn].Version }); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	mark := len(m.history)
+	trashed := make([]apis.Book, len(isbns))
+	for i, isbn := range isbns {
+		before := m.books[isbn]
+		trashed[i] = before
+		trashed[i].DeletedAt = &now
+		trashed[i].Version++
+		if err = m.record(ctx, apis.BookType, isbn, apis.HistoryDelete, before, trashed[i]); err != nil {
+			m.history = m.history[:mark]
+			return 0, err
+		}
+	}
+
+	for i, isbn := range isbns {
+		m.books[isbn] = trashed[i]
+	}
+	return int64(len(isbns)), nil
+}
+
+// checkDeleteVersion checks the resources matched by a delete, identified by their keys, against the version it requires, zero meaning none
+func checkDeleteVersion(kind apis.ResourceType, keys []string, version uint64, versionOf func(key string) uint64) error {
+	switch {
+	case version == 0:
+		return nil
+	case len(keys) == 0:
+		return fmt.Errorf("%w: no %v matches the filters", ErrNotFound, kind)
+	case len(keys) > 1:
+		return fmt.Errorf("%w: a conditional delete requires the filters to match a single %v, %d matched", ErrAmbiguous, kind, len(keys))
+	case versionOf(keys[0]) != version:
+		return fmt.Errorf("%w: %v %v is at version %d", ErrPreconditionFailed, kind, keys[0], versionOf(keys[0]))
+	}
+	return nil
+}
+
+// RestoreBook moves a book out of the trash
+func (m *MemoryHandler) RestoreBook(ctx context.Context, isbn string) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	book, exists := m.books[isbnKey(isbn)]
+	if !exists || book.DeletedAt == nil {
+		return "", fmt.Errorf("%w: book with ISBN %v is not in the trash", ErrNotFound, isbn)
+	}
+	before := book
+	book.DeletedAt = nil
+	book.Version++
+	if err = m.record(ctx, apis.BookType, isbn, apis.HistoryRestore, before, book); err != nil {
+		return "", err
+	}
+	m.books[isbnKey(isbn)] = book
+
+	return fmt.Sprintf("Restored book with ISBN %v", isbn), nil
+}
+
+// Purge permanently removes the books and the collections moved to the trash before the given time
+func (m *MemoryHandler) Purge(ctx context.Context, before time.Time) (books int64, collections int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mark := len(m.history)
+	var isbns, keys []string
+	for isbn, book := range m.books {
+		if book.DeletedAt != nil && book.DeletedAt.Before(before) {
+			if err = m.record(ctx, apis.BookType, isbn, apis.HistoryPurge, book, nil); err != nil {
+				m.history = m.history[:mark]
+				return 0, 0, err
+			}
+			isbns = append(isbns, isbn)
+		}
+	}
+
+	// the purged books are still in the trash, so the members recorded leave them out as their removal does
+	for key, collection := range m.collections {
+		if collection.DeletedAt != nil && collection.DeletedAt.Before(before) {
+			if err = m.record(ctx, apis.CollectionType, collection.Name, apis.HistoryPurge, m.liveMembers(collection), nil); err != nil {
+				m.history = m.history[:mark]
+				return 0, 0, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	for _, isbn := range isbns {
+		delete(m.books, isbn)
+		m.removeMemberships(isbn)
+	}
+	for _, key := range keys {
+		delete(m.collections, key)
+	}
+	return int64(len(isbns)), int64(len(keys)), nil
+}
+
+// removeMemberships removes a purged book from every collection, as the foreign keys of the databases do
+func (m *MemoryHandler) removeMemberships(isbn string) {
+	for key, collection := range m.collections {
+		for i, member := range collection.Books {
+			if isbnKey(member) == isbn {
+				collection.Books = append(collection.Books[:i:i], collection.Books[i+1:]...)
+				m.collections[key] = collection
+				break
+			}
+		}
+	}
+}
+
+// CreateCollection stores a new collection
+func (m *MemoryHandler) CreateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateCollection(collection); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(collection.Name)
+	if _, exists := m.collections[key]; exists {
+		return "", fmt.Errorf("%w: collection %v already exists", ErrConflict, collection.Name)
+	}
+
+	if err = m.booksExist(collection.Books); err != nil {
+		return "", err
+	}
+	collection.Version = 1
+	collection.DeletedAt = nil
+	created := copyCollection(collection)
+	if err = m.record(ctx, apis.CollectionType, collection.Name, apis.HistoryCreate, nil, m.liveMembers(created)); err != nil {
+		return "", err
+	}
+	m.collections[key] = created
+
+	return fmt.Sprintf("Created collection %v with %d book(s)", collection.Name, len(collection.Books)), nil
+}
+
+// UpdateCollection replaces an existing collection, provided it is still at collection.Version if set
+func (m *MemoryHandler) UpdateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateCollection(collection); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(collection.Name)
+	existing, exists := m.collections[key]
+	if !exists || existing.DeletedAt != nil {
+		return "", fmt.Errorf("%w: collection %v does not exist", ErrNotFound, collection.Name)
+	}
+	if collection.Version != 0 && collection.Version != existing.Version {
+		return "", fmt.Errorf("%w: collection %v is at version %d", ErrPreconditionFailed, collection.Name, existing.Version)
+	}
+
+	if err = m.booksExist(collection.Books); err != nil {
+		return "", err
+	}
+	collection.Version = existing.Version + 1
+
+	// the name keeps the case it was created with, as the primary key of the databases
+	updated := copyCollection(collection)
+	updated.Name = existing.Name
+	updated.DeletedAt = nil
+	// the memberships of the books in the trash are kept, so that restoring a book brings it back to its collections
+	for _, isbn := range existing.Books {
+		if m.books[isbnKey(isbn)].DeletedAt != nil {
+			updated.Books = append(updated.Books, isbn)
+		}
+	}
+	updated = copyCollection(&updated)
+	if err = m.record(ctx, apis.CollectionType, existing.Name, apis.HistoryUpdate, m.liveMembers(existing), m.liveMembers(updated)); err != nil {
+		return "", err
+	}
+	m.collections[key] = updated
+
+	return fmt.Sprintf("Updated collection %v with %d book(s)", collection.Name, len(collection.Books)), nil
+}
+
+// GetCollection returns a page of the collections matching the supplied filters
+func (m *MemoryHandler) GetCollection(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.CollectionPage, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !opts.AsOf.IsZero() {
+		return collectionsAsOf(m.statesAsOf(apis.CollectionType, opts.AsOf), m.statesAsOf(apis.BookType, opts.AsOf), filters, opts)
+	}
+
+	var collections []apis.Collection
+	for _, collection := range m.collections {
+		if opts.Deleted.Match(collection.DeletedAt) && filters.Match(&collection) {
+			collections = append(collections, m.liveMembers(collection))
+		}
+	}
+
+	return paginateCollections(collections, opts), nil
+}
+
+// paginateCollections orders the collections and returns the page selected by the options
+func paginateCollections(collections []apis.Collection, opts apis.ListOptions) *apis.CollectionPage {
+	start, end, next := paginate(collections, "Name", compareNames, opts)
+
+	page := &apis.CollectionPage{Items: append([]apis.Collection{}, collections[start:end]...)}
+	page.Total = int64(len(collections))
+	page.NextCursor = next
+	return page
+}
+
+// DeleteCollection moves the collections matching the supplied filters to the trash. A non-zero version requires the filters to match a single collection at that version.
+func (m *MemoryHandler) DeleteCollection(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for key, collection := range m.collections {
+		if collection.DeletedAt == nil && filters.Match(&collection) {
+			keys = append(keys, key)
+		}
+	}
+
+	if err = checkDeleteVersion(apis.CollectionType, keys, version, func(key string) uint64 { return m.collections[key].Version }); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	mark := len(m.history)
+	trashed := make([]apis.Collection, len(keys))
+	for i, key := range keys {
+		trashed[i] = m.collections[key]
+		before := m.liveMembers(trashed[i])
+		trashed[i].DeletedAt = &now
+		trashed[i].Version++
+		if err = m.record(ctx, apis.CollectionType, trashed[i].Name, apis.HistoryDelete, before, m.liveMembers(trashed[i])); err != nil {
+			m.history = m.history[:mark]
+			return 0, err
+		}
+	}
+
+	for i, key := range keys {
+		m.collections[key] = trashed[i]
+	}
+	return int64(len(keys)), nil
+}
+
+// RestoreCollection moves a collection out of the trash together with its members
+func (m *MemoryHandler) RestoreCollection(ctx context.Context, name string) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(name)
+	collection, exists := m.collections[key]
+	if !exists || collection.DeletedAt == nil {
+		return "", fmt.Errorf("%w: collection %v is not in the trash", ErrNotFound, name)
+	}
+	before := m.liveMembers(collection)
+	collection.DeletedAt = nil
+	collection.Version++
+	if err = m.record(ctx, apis.CollectionType, collection.Name, apis.HistoryRestore, before, m.liveMembers(collection)); err != nil {
+		return "", err
+	}
+	m.collections[key] = collection
+
+	return fmt.Sprintf("Restored collection %v", name), nil
+}
+
+// AddBookToCollection adds an existing book to an existing collection
+func (m *MemoryHandler) AddBookToCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, exists := m.collections[strings.ToLower(name)]
+	if !exists || collection.DeletedAt != nil {
+		return "", fmt.Errorf("%w: collection %v does not exist", ErrNotFound, name)
+	}
+
+	if book, exists := m.books[isbnKey(isbn)]; !exists || book.DeletedAt != nil {
+		return "", fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, isbn)
+	}
+
+	for _, member := range collection.Books {
+		if isbnKey(member) == isbnKey(isbn) {
+			return "", fmt.Errorf("%w: book with ISBN %v is already in collection %v", ErrConflict, isbn, name)
+		}
+	}
+
+	// the member is stored by value, as the databases do
+	before := m.liveMembers(collection)
+	collection.Books = append(collection.Books, isbnKey(isbn))
+	collection.Version++
+	if err = m.record(ctx, apis.CollectionType, collection.Name, apis.HistoryUpdate, before, m.liveMembers(collection)); err != nil {
+		return "", err
+	}
+	m.collections[strings.ToLower(name)] = copyCollection(&collection)
+
+	return fmt.Sprintf("Added book with ISBN %v to collection %v", isbn, name), nil
+}
+
+// RemoveBookFromCollection removes a book from a collection
+func (m *MemoryHandler) RemoveBookFromCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	if err = ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collection, exists := m.collections[strings.ToLower(name)]
+	if exists && collection.DeletedAt == nil {
+		for i, member := range collection.Books {
+			if isbnKey(member) == isbnKey(isbn) {
+				before := m.liveMembers(collection)
+				collection.Books = append(collection.Books[:i:i], collection.Books[i+1:]...)
+				collection.Version++
+				if err = m.record(ctx, apis.CollectionType, collection.Name, apis.HistoryUpdate, before, m.liveMembers(collection)); err != nil {
+					return "", err
+				}
+				m.collections[strings.ToLower(name)] = collection
+				return fmt.Sprintf("Removed book with ISBN %v from collection %v", isbn, name), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%w: book with ISBN %v is not in collection %v", ErrNotFound, isbn, name)
+}
+
+// record appends to the history the change of a resource between the two states, nil meaning that the resource did not exist.
+// The callers record a change before applying it, and skip it if the history cannot be recorded, as the SQL handlers roll back their transaction:
+// a change is never applied without its history, which the listings as of a past time are built from.
+// The changes of several resources are recorded all before being applied, and their entries are truncated if any of them fails.
+func (m *MemoryHandler) record(ctx context.Context, kind apis.ResourceType, id string, op apis.HistoryOperation, before interface{}, after interface{}) error {
+	beforeState, err := marshalState(before)
+	if err != nil {
+		return fmt.Errorf("%w: record history: marshal %v %v: %v", ErrInternal, kind, id, err)
+	}
+
+	afterState, err := marshalState(after)
+	if err != nil {
+		return fmt.Errorf("%w: record history: marshal %v %v: %v", ErrInternal, kind, id, err)
+	}
+
+	entry, err := historyEntry(kind, historyID(kind, id), op, principalFrom(ctx), time.Now().UTC(), beforeState, afterState)
+	if err != nil {
+		return fmt.Errorf("%w: record history: %v", ErrInternal, err)
+	}
+	m.history = append(m.history, entry)
+	return nil
+}
+
+// History returns the changes of the resource in the order they happened. It returns ErrNotFound if the resource has never been changed.
+func (m *MemoryHandler) History(ctx context.Context, kind apis.ResourceType, id string) (entries []apis.HistoryEntry, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if kind == apis.BookType {
+		if err = validateBook(&apis.Book{Isbn: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries = []apis.HistoryEntry{}
+	for _, entry := range m.history {
+		if entry.Resource == kind && entry.ID == historyID(kind, id) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: %v %v has no history", ErrNotFound, kind, id)
+	}
+	return entries, nil
+}
+
+// Close does nothing, as there is no connection to release
+func (m *MemoryHandler) Close() error {
+	return nil
+}
+
+// booksExist returns ErrValidation if any of the books does not exist or is in the trash, as the SQL handlers do
+func (m *MemoryHandler) booksExist(isbns []string) error {
+	for _, isbn := range isbns {
+		if book, exists := m.books[isbnKey(isbn)]; !exists || book.DeletedAt != nil {
+			return fmt.Errorf("%w: book with ISBN %v does not exist", ErrValidation, isbn)
+		}
+	}
+	return nil
+}
+
+// liveMembers returns a copy of the collection leaving out the books in the trash
+func (m *MemoryHandler) liveMembers(collection apis.Collection) apis.Collection {
+	c := copyCollection(&collection)
+	live := c.Books[:0]
+	for _, isbn := range c.Books {
+		if m.books[isbnKey(isbn)].DeletedAt == nil {
+			live = append(live, isbn)
+		}
+	}
+	c.Books = live
+	return c
+}
+
+// copyCollection returns a copy of the collection that does not share the members, which are sorted by ISBN as in the SQL handlers
+func copyCollection(collection *apis.Collection) apis.Collection {
+	c := *collection
+	c.Books = append([]string{}, collection.Books...)
+	sort.Slice(c.Books, func(i, j int) bool {
+		return apis.CompareIdentifiers(c.Books[i], c.Books[j]) < 0
+	})
+	return c
+}
+
+// compareNames compares two collection names ignoring case
+func compareNames(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
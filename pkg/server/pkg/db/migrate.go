@@ -0,0 +1,339 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is a versioned change of the database schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations of a dialect and keeps track of them in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	dialect    apis.Dialect
+	migrations []Migration
+}
+
+// NewMigrator opens the connection to the database selected by the options and returns a Migrator for it
+func NewMigrator(opts Options) (*Migrator, error) {
+	db, dialect, err := openDB(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMigrator(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMigrator returns a Migrator that uses an already opened database
+func newMigrator(db *sql.DB, dialect apis.Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: %v", err)
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+	}, nil
+}
+
+// Close closes the connection to the database
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Latest returns the version of the most recent embedded migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status returns all the known migrations ordered by version together with their state
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		status = append(status, MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// Up applies all the pending migrations in order and returns them
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		insert := fmt.Sprintf("INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s)", apis.Placeholders(m.dialect, 0, 3))
+		err = m.run(migration.Up, insert, migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return done, fmt.Errorf("apply migration %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the most recent applied migration and returns it. It returns nil if no migration is applied.
+func (m *Migrator) Down() (*Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		remove := fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %s", m.dialect.Placeholder(1))
+		err = m.run(migration.Down, remove, migration.Version)
+		if err != nil {
+			return nil, fmt.Errorf("roll back migration %04d_%s: %v", migration.Version, migration.Name, err)
+		}
+		return &migration, nil
+	}
+	return nil, nil
+}
+
+// Check returns an error if the database schema does not match the embedded migrations
+func (m *Migrator) Check() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if _, ok := applied[migration.Version]; !ok {
+			return fmt.Errorf("db schema is out of date: migration %04d_%s is not applied, run `book-server migrate up`", migration.Version, migration.Name)
+		}
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("db schema is newer than the server: migration %04d is unknown", version)
+		}
+	}
+	return nil
+}
+
+// run executes the statements of a migration and the bookkeeping query within a single transaction.
+// MySQL implicitly commits DDL statements, so a failing migration may be partially applied there.
+func (m *Migrator) run(script string, bookkeeping string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return fmt.Errorf("execute statement: %v", err)
+		}
+	}
+
+	_, err = tx.Exec(bookkeeping, args...)
+	if err != nil {
+		return fmt.Errorf("update schema_migrations: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// applied returns the applied migration versions and when they were applied. The schema_migrations table is created if needed.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	_, err := m.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL, PRIMARY KEY (version))")
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations table: %v", err)
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations table: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan row: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// loadMigrations reads the embedded migrations of the dialect. Files are named VERSION_NAME.up.sql and VERSION_NAME.down.sql.
+func loadMigrations(dialect apis.Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", dialect.Name())
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %v", name)
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(name, "."+direction+".sql"), "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %v", name)
+		}
+
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %v", name)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down scripts", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// splitStatements splits a SQL script on the semicolons that are not part of quoted strings or comments
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	comment := false
+
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case comment:
+			if r == '\n' {
+				comment = false
+				current.WriteRune(r)
+			}
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			comment = true
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+
+	return statements
+}
+
+// checkSchema returns an error if the schema of an opened database is not up to date
+func checkSchema(db *sql.DB, dialect apis.Dialect) error {
+	migrator, err := newMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+	return migrator.Check()
+}
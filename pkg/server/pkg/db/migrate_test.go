@@ -0,0 +1,136 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- a comment; with a semicolon
+CREATE TABLE a (b TEXT DEFAULT 'x;y');
+CREATE TABLE "c;d" (e TEXT);
+
+`
+	require.Equal(t, []string{
+		"CREATE TABLE a (b TEXT DEFAULT 'x;y')",
+		`CREATE TABLE "c;d" (e TEXT)`,
+	}, splitStatements(script))
+}
+
+func TestMigrator(t *testing.T) {
+	for _, dialect := range []apis.Dialect{apis.MySQL, apis.Postgres, apis.SQLite} {
+		migrations, err := loadMigrations(dialect)
+		require.Nil(t, err, dialect.Name())
+		require.NotEmpty(t, migrations, dialect.Name())
+	}
+
+	db, err := openSQLite(Options{File: filepath.Join(t.TempDir(), "migrate.db")})
+	require.Nil(t, err)
+
+	migrator, err := newMigrator(db, apis.SQLite)
+	require.Nil(t, err)
+	defer migrator.Close()
+
+	require.Error(t, migrator.Check())
+
+	applied, err := migrator.Up()
+	require.Nil(t, err)
+	require.Len(t, applied, len(migrator.migrations))
+	require.Nil(t, migrator.Check())
+
+	applied, err = migrator.Up()
+	require.Nil(t, err)
+	require.Empty(t, applied)
+
+	status, err := migrator.Status()
+	require.Nil(t, err)
+	for _, s := range status {
+		require.True(t, s.Applied)
+		require.False(t, s.AppliedAt.IsZero())
+	}
+
+	rolledBack, err := migrator.Down()
+	require.Nil(t, err)
+	require.Equal(t, migrator.Latest(), rolledBack.Version)
+	require.Error(t, migrator.Check())
+}
+
+func TestMigrateHistoryOfExistingRows(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "migrate.db")
+	db, err := openSQLite(Options{File: file})
+	require.Nil(t, err)
+
+	migrator, err := newMigrator(db, apis.SQLite)
+	require.Nil(t, err)
+
+	// the resources are created before the history is introduced
+	var beforeHistory []Migration
+	for _, migration := range migrator.migrations {
+		if migration.Name != "history" {
+			beforeHistory = append(beforeHistory, migration)
+			continue
+		}
+		break
+	}
+	migrator.migrations = beforeHistory
+	_, err = migrator.Up()
+	require.Nil(t, err)
+
+	deletedAt := time.Date(2025, 12, 31, 10, 0, 0, 500, time.UTC)
+	for _, stmt := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"INSERT INTO books (isbn, title, author, published_date, edition, genre, version) VALUES (1, 'Hamlet', 'William Shakespeare', '1603-01-01', 2, 'Drama', 3)", nil},
+		{"INSERT INTO books (isbn, title, deleted_at) VALUES (2, 'Macbeth', ?)", []interface{}{deletedAt}},
+		{"INSERT INTO collections (name, description, creation_date) VALUES ('Classics', 'Old books', '2020-01-01')", nil},
+		{"INSERT INTO collection_members (collection_name, book_isbn) VALUES ('Classics', 1), ('Classics', 2)", nil},
+	} {
+		_, err = db.Exec(stmt.query, stmt.args...)
+		require.Nil(t, err, stmt.query)
+	}
+	require.Nil(t, migrator.Close())
+
+	handler, err := NewSQLiteHandler(Options{File: file})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	live, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{Deleted: apis.IncludeDeleted})
+	require.Nil(t, err)
+	require.Len(t, live.Items, 2)
+
+	for _, book := range live.Items {
+		entries, err := handler.History(ctx, apis.BookType, book.Isbn)
+		require.Nil(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, apis.HistoryCreate, entries[0].Operation)
+		require.Equal(t, "migration", entries[0].Principal)
+		require.Nil(t, entries[0].Before)
+
+		recorded := apis.Book{}
+		require.Nil(t, json.Unmarshal(entries[0].After, &recorded))
+		require.Equal(t, book, recorded)
+	}
+
+	entries, err := handler.History(ctx, apis.CollectionType, "classics")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.JSONEq(t, `{"name": "Classics", "description": "Old books", "creation_date": "2020-01-01", "books": ["1"], "version": 1, "deleted_at": null}`, string(entries[0].After))
+
+	// the past states include the existing resources from the migration on
+	past, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{Deleted: apis.IncludeDeleted, AsOf: time.Now().UTC()})
+	require.Nil(t, err)
+	require.Equal(t, live.Items, past.Items)
+
+	collections, err := handler.GetCollection(ctx, allCollections(t), apis.ListOptions{AsOf: time.Now().UTC()})
+	require.Nil(t, err)
+	require.Len(t, collections.Items, 1)
+	require.Equal(t, []string{"1"}, collections.Items[0].Books)
+}
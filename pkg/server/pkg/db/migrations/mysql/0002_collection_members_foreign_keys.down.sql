@@ -0,0 +1,5 @@
+ALTER TABLE `collection_members`
+	DROP FOREIGN KEY `collection_members_book`,
+	DROP FOREIGN KEY `collection_members_collection`;
+
+ALTER TABLE `collection_members` DROP KEY `book_isbn`;
@@ -0,0 +1,2 @@
+ALTER TABLE `collections` DROP KEY `deleted_at`, DROP COLUMN `deleted_at`;
+ALTER TABLE `books` DROP KEY `deleted_at`, DROP COLUMN `deleted_at`;
@@ -0,0 +1,3 @@
+-- deleted_at marks the resources moved to the trash, NULL for the live ones
+ALTER TABLE `books` ADD COLUMN `deleted_at` DATETIME(6) NULL DEFAULT NULL, ADD KEY `deleted_at` (`deleted_at`) USING BTREE;
+ALTER TABLE `collections` ADD COLUMN `deleted_at` DATETIME(6) NULL DEFAULT NULL, ADD KEY `deleted_at` (`deleted_at`) USING BTREE;
@@ -0,0 +1,42 @@
+-- history records every change of the books and the collections. Entries are kept when the resources are purged.
+CREATE TABLE IF NOT EXISTS `history` (
+	`id` BIGINT NOT NULL AUTO_INCREMENT,
+	`resource_type` VARCHAR(15) NOT NULL,
+	`resource_id` VARCHAR(30) NOT NULL,
+	`operation` VARCHAR(10) NOT NULL,
+	`principal` VARCHAR(64) NOT NULL DEFAULT '',
+	`changed_at` DATETIME(6) NOT NULL,
+	`before_state` MEDIUMTEXT,
+	`after_state` MEDIUMTEXT,
+	KEY `resource` (`resource_type`, `resource_id`, `id`) USING BTREE,
+	PRIMARY KEY (`id`)
+);
+
+-- the existing resources get a create entry, so that the past states include them from now on. The states are the JSON of apis.Book and apis.Collection.
+INSERT INTO `history` (`resource_type`, `resource_id`, `operation`, `principal`, `changed_at`, `before_state`, `after_state`)
+	SELECT 'book', CAST(`isbn` AS CHAR), 'create', 'migration', UTC_TIMESTAMP(6), NULL, JSON_OBJECT(
+		'title', `title`,
+		'author', `author`,
+		'isbn', CAST(`isbn` AS CHAR),
+		'published_date', COALESCE(DATE_FORMAT(`published_date`, '%Y-%m-%d'), '0001-01-01'),
+		'edition', COALESCE(`edition` + 0, 0),
+		'description', COALESCE(`description`, ''),
+		'genre', COALESCE(`genre`, ''),
+		'version', `version`,
+		'deleted_at', DATE_FORMAT(`deleted_at`, '%Y-%m-%dT%H:%i:%s.%fZ')
+	)
+	FROM `books`;
+
+INSERT INTO `history` (`resource_type`, `resource_id`, `operation`, `principal`, `changed_at`, `before_state`, `after_state`)
+	SELECT 'collection', LOWER(`name`), 'create', 'migration', UTC_TIMESTAMP(6), NULL, JSON_OBJECT(
+		'name', `name`,
+		'description', COALESCE(`description`, ''),
+		'creation_date', COALESCE(DATE_FORMAT(`creation_date`, '%Y-%m-%d'), '0001-01-01'),
+		'books', COALESCE((
+			SELECT JSON_ARRAYAGG(CAST(m.`book_isbn` AS CHAR)) FROM `collection_members` m JOIN `books` b ON b.`isbn` = m.`book_isbn`
+			WHERE m.`collection_name` = c.`name` AND b.`deleted_at` IS NULL
+		), JSON_ARRAY()),
+		'version', `version`,
+		'deleted_at', DATE_FORMAT(`deleted_at`, '%Y-%m-%dT%H:%i:%s.%fZ')
+	)
+	FROM `collections` c;
@@ -0,0 +1,35 @@
+-- citext keeps text comparisons case-insensitive as with the default MySQL collation
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE IF NOT EXISTS books (
+	title CITEXT NOT NULL DEFAULT '' CHECK (char_length(title) <= 50),
+	isbn BIGINT NOT NULL,
+	author CITEXT NOT NULL DEFAULT '' CHECK (char_length(author) <= 30),
+	published_date DATE,
+	edition SMALLINT CHECK (edition BETWEEN 0 AND 255),
+	description CITEXT,
+	genre CITEXT CHECK (char_length(genre) <= 15),
+	PRIMARY KEY (isbn)
+);
+
+CREATE INDEX IF NOT EXISTS books_title ON books USING HASH (title);
+CREATE INDEX IF NOT EXISTS books_author ON books USING HASH (author);
+CREATE INDEX IF NOT EXISTS books_dates ON books USING BTREE (published_date);
+CREATE INDEX IF NOT EXISTS books_genre ON books USING HASH (genre);
+
+CREATE TABLE IF NOT EXISTS collections (
+	name CITEXT NOT NULL CHECK (char_length(name) <= 30),
+	description TEXT,
+	creation_date DATE,
+	PRIMARY KEY (name)
+);
+
+CREATE INDEX IF NOT EXISTS collections_creation_date ON collections USING BTREE (creation_date);
+
+CREATE TABLE IF NOT EXISTS collection_members (
+	collection_name CITEXT NOT NULL,
+	book_isbn BIGINT NOT NULL,
+	PRIMARY KEY (collection_name, book_isbn)
+);
+
+CREATE INDEX IF NOT EXISTS collection_members_collection_name ON collection_members USING HASH (collection_name);
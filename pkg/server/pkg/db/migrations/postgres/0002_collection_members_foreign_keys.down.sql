@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS collection_members_book_isbn;
+
+ALTER TABLE collection_members
+	DROP CONSTRAINT IF EXISTS collection_members_book,
+	DROP CONSTRAINT IF EXISTS collection_members_collection;
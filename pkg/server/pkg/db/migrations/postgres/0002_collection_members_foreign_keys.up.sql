@@ -0,0 +1,9 @@
+-- memberships referring to missing books or collections would violate the new constraints
+DELETE FROM collection_members WHERE book_isbn NOT IN (SELECT isbn FROM books);
+DELETE FROM collection_members WHERE collection_name NOT IN (SELECT name FROM collections);
+
+ALTER TABLE collection_members
+	ADD CONSTRAINT collection_members_book FOREIGN KEY (book_isbn) REFERENCES books (isbn) ON DELETE CASCADE,
+	ADD CONSTRAINT collection_members_collection FOREIGN KEY (collection_name) REFERENCES collections (name) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS collection_members_book_isbn ON collection_members USING BTREE (book_isbn);
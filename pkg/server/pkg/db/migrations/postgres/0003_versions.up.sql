@@ -0,0 +1,3 @@
+-- version is incremented on every change and used for optimistic concurrency control
+ALTER TABLE books ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1 CHECK (version > 0);
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1 CHECK (version > 0);
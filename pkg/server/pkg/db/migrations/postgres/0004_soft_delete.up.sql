@@ -0,0 +1,6 @@
+-- deleted_at marks the resources moved to the trash, NULL for the live ones
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL DEFAULT NULL;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS books_deleted_at ON books USING BTREE (deleted_at);
+CREATE INDEX IF NOT EXISTS collections_deleted_at ON collections USING BTREE (deleted_at);
@@ -0,0 +1,43 @@
+-- history records every change of the books and the collections. Entries are kept when the resources are purged.
+CREATE TABLE IF NOT EXISTS history (
+	id BIGSERIAL NOT NULL,
+	resource_type VARCHAR(15) NOT NULL,
+	resource_id VARCHAR(30) NOT NULL,
+	operation VARCHAR(10) NOT NULL,
+	principal VARCHAR(64) NOT NULL DEFAULT '',
+	changed_at TIMESTAMP NOT NULL,
+	before_state TEXT,
+	after_state TEXT,
+	PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS history_resource ON history USING BTREE (resource_type, resource_id, id);
+
+-- the existing resources get a create entry, so that the past states include them from now on. The states are the JSON of apis.Book and apis.Collection.
+INSERT INTO history (resource_type, resource_id, operation, principal, changed_at, before_state, after_state)
+	SELECT 'book', isbn::text, 'create', 'migration', now() AT TIME ZONE 'UTC', NULL, json_build_object(
+		'title', title::text,
+		'author', author::text,
+		'isbn', isbn::text,
+		'published_date', COALESCE(to_char(published_date, 'YYYY-MM-DD'), '0001-01-01'),
+		'edition', COALESCE(edition, 0),
+		'description', COALESCE(description::text, ''),
+		'genre', COALESCE(genre::text, ''),
+		'version', version,
+		'deleted_at', to_char(deleted_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+	)::text
+	FROM books;
+
+INSERT INTO history (resource_type, resource_id, operation, principal, changed_at, before_state, after_state)
+	SELECT 'collection', lower(name::text), 'create', 'migration', now() AT TIME ZONE 'UTC', NULL, json_build_object(
+		'name', name::text,
+		'description', COALESCE(description, ''),
+		'creation_date', COALESCE(to_char(creation_date, 'YYYY-MM-DD'), '0001-01-01'),
+		'books', COALESCE((
+			SELECT json_agg(m.book_isbn::text ORDER BY m.book_isbn) FROM collection_members m JOIN books b ON b.isbn = m.book_isbn
+			WHERE m.collection_name = c.name AND b.deleted_at IS NULL
+		), '[]'::json),
+		'version', version,
+		'deleted_at', to_char(deleted_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+	)::text
+	FROM collections c;
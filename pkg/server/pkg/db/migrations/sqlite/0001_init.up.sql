@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS `books` (
+	`title` VARCHAR(50) NOT NULL DEFAULT '' COLLATE NOCASE,
+	`isbn` BIGINT(13) NOT NULL,
+	`author` VARCHAR(30) NOT NULL DEFAULT '' COLLATE NOCASE,
+	`published_date` DATE,
+	`edition` TINYINT,
+	`description` TEXT COLLATE NOCASE,
+	`genre` VARCHAR(15) COLLATE NOCASE,
+	PRIMARY KEY (`isbn`)
+);
+
+CREATE INDEX IF NOT EXISTS `title` ON `books` (`title`);
+CREATE INDEX IF NOT EXISTS `author` ON `books` (`author`);
+CREATE INDEX IF NOT EXISTS `dates` ON `books` (`published_date`);
+CREATE INDEX IF NOT EXISTS `genre` ON `books` (`genre`);
+
+CREATE TABLE IF NOT EXISTS `collections` (
+	`name` VARCHAR(30) NOT NULL COLLATE NOCASE,
+	`description` TEXT,
+	`creation_date` DATE,
+	PRIMARY KEY (`name`)
+);
+
+CREATE INDEX IF NOT EXISTS `creation_date` ON `collections` (`creation_date`);
+
+CREATE TABLE IF NOT EXISTS `collection_members` (
+	`collection_name` VARCHAR(30) NOT NULL COLLATE NOCASE,
+	`book_isbn` BIGINT(13) NOT NULL,
+	PRIMARY KEY (`collection_name`,`book_isbn`)
+);
+
+CREATE INDEX IF NOT EXISTS `collection_name` ON `collection_members` (`collection_name`);
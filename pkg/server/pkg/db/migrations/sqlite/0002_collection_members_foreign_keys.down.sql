@@ -0,0 +1,13 @@
+CREATE TABLE `collection_members_old` (
+	`collection_name` VARCHAR(30) NOT NULL COLLATE NOCASE,
+	`book_isbn` BIGINT(13) NOT NULL,
+	PRIMARY KEY (`collection_name`,`book_isbn`)
+);
+
+INSERT INTO `collection_members_old` (`collection_name`, `book_isbn`)
+	SELECT `collection_name`, `book_isbn` FROM `collection_members`;
+
+DROP TABLE `collection_members`;
+ALTER TABLE `collection_members_old` RENAME TO `collection_members`;
+
+CREATE INDEX IF NOT EXISTS `collection_name` ON `collection_members` (`collection_name`);
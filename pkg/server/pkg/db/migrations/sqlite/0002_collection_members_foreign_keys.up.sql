@@ -0,0 +1,17 @@
+-- SQLite cannot add constraints to an existing table: the members table is rebuilt with the foreign keys,
+-- dropping the memberships that refer to missing books or collections
+CREATE TABLE `collection_members_new` (
+	`collection_name` VARCHAR(30) NOT NULL COLLATE NOCASE REFERENCES `collections` (`name`) ON DELETE CASCADE,
+	`book_isbn` BIGINT(13) NOT NULL REFERENCES `books` (`isbn`) ON DELETE CASCADE,
+	PRIMARY KEY (`collection_name`,`book_isbn`)
+);
+
+INSERT INTO `collection_members_new` (`collection_name`, `book_isbn`)
+	SELECT `collection_name`, `book_isbn` FROM `collection_members`
+	WHERE `book_isbn` IN (SELECT `isbn` FROM `books`) AND `collection_name` IN (SELECT `name` FROM `collections`);
+
+DROP TABLE `collection_members`;
+ALTER TABLE `collection_members_new` RENAME TO `collection_members`;
+
+CREATE INDEX IF NOT EXISTS `collection_name` ON `collection_members` (`collection_name`);
+CREATE INDEX IF NOT EXISTS `book_isbn` ON `collection_members` (`book_isbn`);
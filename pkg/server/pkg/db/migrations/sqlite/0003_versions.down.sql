@@ -0,0 +1,2 @@
+ALTER TABLE `collections` DROP COLUMN `version`;
+ALTER TABLE `books` DROP COLUMN `version`;
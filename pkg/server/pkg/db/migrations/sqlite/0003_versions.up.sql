@@ -0,0 +1,3 @@
+-- version is incremented on every change and used for optimistic concurrency control
+ALTER TABLE `books` ADD COLUMN `version` BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE `collections` ADD COLUMN `version` BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS `collections_deleted_at`;
+DROP INDEX IF EXISTS `books_deleted_at`;
+
+ALTER TABLE `collections` DROP COLUMN `deleted_at`;
+ALTER TABLE `books` DROP COLUMN `deleted_at`;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `history_resource`;
+DROP TABLE IF EXISTS `history`;
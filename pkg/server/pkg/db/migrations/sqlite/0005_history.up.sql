@@ -0,0 +1,43 @@
+-- history records every change of the books and the collections. Entries are kept when the resources are purged.
+CREATE TABLE IF NOT EXISTS `history` (
+	`id` INTEGER PRIMARY KEY AUTOINCREMENT,
+	`resource_type` VARCHAR(15) NOT NULL,
+	`resource_id` VARCHAR(30) NOT NULL,
+	`operation` VARCHAR(10) NOT NULL,
+	`principal` VARCHAR(64) NOT NULL DEFAULT '',
+	`changed_at` TIMESTAMP NOT NULL,
+	`before_state` TEXT,
+	`after_state` TEXT
+);
+
+CREATE INDEX IF NOT EXISTS `history_resource` ON `history` (`resource_type`, `resource_id`, `id`);
+
+-- the existing resources get a create entry, so that the past states include them from now on.
+-- The states are the JSON of apis.Book and apis.Collection, timestamps being stored in the layout of the Go time.Time String method.
+INSERT INTO `history` (`resource_type`, `resource_id`, `operation`, `principal`, `changed_at`, `before_state`, `after_state`)
+	SELECT 'book', CAST(`isbn` AS TEXT), 'create', 'migration', strftime('%Y-%m-%d %H:%M:%f', 'now') || ' +0000 UTC', NULL, json_object(
+		'title', `title`,
+		'author', `author`,
+		'isbn', CAST(`isbn` AS TEXT),
+		'published_date', COALESCE(substr(`published_date`, 1, 10), '0001-01-01'),
+		'edition', COALESCE(`edition`, 0),
+		'description', COALESCE(`description`, ''),
+		'genre', COALESCE(`genre`, ''),
+		'version', `version`,
+		'deleted_at', replace(substr(`deleted_at`, 1, instr(`deleted_at`, ' +') - 1), ' ', 'T') || 'Z'
+	)
+	FROM `books`;
+
+INSERT INTO `history` (`resource_type`, `resource_id`, `operation`, `principal`, `changed_at`, `before_state`, `after_state`)
+	SELECT 'collection', lower(`name`), 'create', 'migration', strftime('%Y-%m-%d %H:%M:%f', 'now') || ' +0000 UTC', NULL, json_object(
+		'name', `name`,
+		'description', COALESCE(`description`, ''),
+		'creation_date', COALESCE(substr(`creation_date`, 1, 10), '0001-01-01'),
+		'books', (SELECT json_group_array(CAST(`isbn` AS TEXT)) FROM (
+			SELECT m.`book_isbn` AS `isbn` FROM `collection_members` m JOIN `books` b ON b.`isbn` = m.`book_isbn`
+			WHERE m.`collection_name` = c.`name` AND b.`deleted_at` IS NULL ORDER BY m.`book_isbn`
+		)),
+		'version', `version`,
+		'deleted_at', replace(substr(`deleted_at`, 1, instr(`deleted_at`, ' +') - 1), ' ', 'T') || 'Z'
+	)
+	FROM `collections` c;
@@ -1,6 +1,24 @@
 package db
 
-import "flag"
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+const (
+	// MySQLDriver selects the MySQLHandler
+	MySQLDriver = "mysql"
+	// PostgresDriver selects the PostgresHandler
+	PostgresDriver = "postgres"
+	// SQLiteDriver selects the SQLiteHandler
+	SQLiteDriver = "sqlite"
+	// MemoryDriver selects the MemoryHandler
+	MemoryDriver = "memory"
+)
+
+// Driver is the database implementation to use
+var Driver string
 
 // Host is the host of the database
 var Host string
@@ -17,23 +35,138 @@ var Pass string
 // DB is the database to use
 var DB string
 
+// File is the path of the SQLite database file
+var File string
+
+// QueryTimeout is the deadline of every query
+var QueryTimeout time.Duration
+
+// TrashRetention is how long the deleted resources are kept in the trash before being purged
+var TrashRetention time.Duration
+
+// MaxOpenConns is the maximum number of open connections to the database
+var MaxOpenConns int
+
+// MaxIdleConns is the maximum number of idle connections kept open
+var MaxIdleConns int
+
+// ConnMaxLifetime is how long a connection is reused before being closed
+var ConnMaxLifetime time.Duration
+
+// DSNParams are additional parameters of the database connection
+var DSNParams string
+
+// WaitTimeout is how long the server waits for the database to be reachable on startup
+var WaitTimeout time.Duration
+
+// Replicas are the addresses of the read-only replicas of the database
+var Replicas string
+
+// ReadYourWrites is how long the reads of a client stick to the primary after its writes
+var ReadYourWrites time.Duration
+
+// ReplicaCheckInterval is how often the health and the lag of the replicas are checked
+var ReplicaCheckInterval time.Duration
+
+// MaxReplicaLag is how far behind the primary a replica can be and still serve the reads
+var MaxReplicaLag time.Duration
+
+// CacheSize is the maximum number of listings kept by the cache
+var CacheSize int
+
+// CacheTTL is how long the cache keeps a listing
+var CacheTTL time.Duration
+
 // Options is the options for the database
 type Options struct {
-	Host string
-	Port string
-	User string
-	Pass string
-	DB   string
+	Driver string
+	Host   string
+	Port   string
+	User   string
+	Pass   string
+	DB     string
+	File   string
+	// QueryTimeout bounds the duration of every query. Zero means no deadline.
+	QueryTimeout time.Duration
+	// TrashRetention is how long the deleted resources are kept in the trash before being purged
+	TrashRetention time.Duration
+	// MaxOpenConns bounds the connections to the MySQL and PostgreSQL databases. Zero means no limit.
+	MaxOpenConns int
+	// MaxIdleConns is the number of idle connections kept open. Zero keeps the default of database/sql.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection is reused before being closed, so that the server closes it before the database does. Zero reuses it forever.
+	ConnMaxLifetime time.Duration
+	// DSNParams are additional connection parameters in query string form (e.g. `tls=true&timeout=5s` for MySQL, `sslmode=require` for PostgreSQL)
+	DSNParams string
+	// WaitTimeout is how long to wait for the database to be reachable when connecting. Zero gives a single attempt.
+	WaitTimeout time.Duration
+	// Replicas are the host:port addresses of the replicas of the MySQL or PostgreSQL database serving the reads. They share the credentials of the primary.
+	Replicas []string
+	// ReadYourWrites is how long the reads of a client, see WithClient, go to the primary after each of its writes. Zero always reads from the replicas.
+	ReadYourWrites time.Duration
+	// ReplicaCheckInterval is how often the replicas are checked. Zero disables the checks: a replica is then only left out for a while when a read fails.
+	ReplicaCheckInterval time.Duration
+	// MaxReplicaLag leaves out of the reads the replicas that the checks find further behind the primary. Zero does not limit the lag.
+	MaxReplicaLag time.Duration
+	// CacheSize enables the CachingHandler keeping up to that many listings. Zero disables the cache.
+	CacheSize int
+	// CacheTTL is how long the cache keeps a listing. Zero keeps it until it is evicted or a write drops it.
+	CacheTTL time.Duration
 }
 
-// NewDBOptions creates the new database options
+// NewDBOptions creates the new database options. If no port is supplied, the default one of the driver is used.
 func NewDBOptions() Options {
+	port := Port
+	if port == "" {
+		port = defaultPort(Driver)
+	}
+
 	return Options{
-		Host: Host,
-		Port: Port,
-		User: User,
-		Pass: Pass,
-		DB:   DB,
+		Driver: Driver,
+		Host:   Host,
+		Port:   port,
+		User:   User,
+		Pass:   Pass,
+		DB:     DB,
+		File:   File,
+
+		QueryTimeout:   QueryTimeout,
+		TrashRetention: TrashRetention,
+
+		MaxOpenConns:    MaxOpenConns,
+		MaxIdleConns:    MaxIdleConns,
+		ConnMaxLifetime: ConnMaxLifetime,
+		DSNParams:       DSNParams,
+		WaitTimeout:     WaitTimeout,
+
+		Replicas:             splitList(Replicas),
+		ReadYourWrites:       ReadYourWrites,
+		ReplicaCheckInterval: ReplicaCheckInterval,
+		MaxReplicaLag:        MaxReplicaLag,
+
+		CacheSize: CacheSize,
+		CacheTTL:  CacheTTL,
+	}
+}
+
+// splitList splits a comma separated list, leaving out the empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// defaultPort returns the port the database server listens on by default
+func defaultPort(driver string) string {
+	switch driver {
+	case PostgresDriver:
+		return "5432"
+	default:
+		return "3306"
 	}
 }
 
@@ -43,9 +176,24 @@ func (o Options) Address() string {
 }
 
 func init() {
+	flag.StringVar(&Driver, "driver", MySQLDriver, "DB driver. One of: mysql, postgres, sqlite, memory.")
 	flag.StringVar(&Host, "host", "localhost", "DB host.")
-	flag.StringVar(&Port, "port", "3306", "DB port.")
+	flag.StringVar(&Port, "port", "", "DB port. Defaults to 3306 for mysql and 5432 for postgres.")
 	flag.StringVar(&User, "user", "root", "DB user.")
 	flag.StringVar(&Pass, "password", "secret", "DB password.")
 	flag.StringVar(&DB, "db", "book_management", "The DB name to use.")
+	flag.DurationVar(&QueryTimeout, "query-timeout", 10*time.Second, "Deadline of every DB query. Zero disables it.")
+	flag.DurationVar(&TrashRetention, "trash-retention", 30*24*time.Hour, "How long the deleted books and collections are kept in the trash before the purge command removes them.")
+	flag.IntVar(&MaxOpenConns, "max-open-conns", 20, "Maximum number of open DB connections. Zero means no limit.")
+	flag.IntVar(&MaxIdleConns, "max-idle-conns", 10, "Maximum number of idle DB connections kept open.")
+	flag.DurationVar(&ConnMaxLifetime, "conn-max-lifetime", 5*time.Minute, "How long a DB connection is reused. It should be shorter than the timeout of the idle connections of the DB. Zero reuses connections forever.")
+	flag.StringVar(&DSNParams, "dsn-params", "", "Additional DB connection parameters in query string form, e.g. tls=true&timeout=5s for mysql or sslmode=require for postgres.")
+	flag.DurationVar(&WaitTimeout, "wait-timeout", 30*time.Second, "How long to wait on startup for the DB to be reachable. Zero gives up after the first attempt.")
+	flag.StringVar(&Replicas, "replicas", "", "Comma separated host:port addresses of the DB replicas serving the reads, e.g. replica1:3306,replica2:3306. The port defaults to the one of the primary.")
+	flag.DurationVar(&ReadYourWrites, "read-your-writes", 5*time.Second, "How long the reads of a client go to the primary DB after its writes, so that it sees them even if the replicas lag behind. Clients are told apart by their bearer token or session cookie, or else by their address.")
+	flag.DurationVar(&ReplicaCheckInterval, "replica-check-interval", 5*time.Second, "How often the health and the lag of the DB replicas are checked. Zero disables the checks.")
+	flag.DurationVar(&MaxReplicaLag, "max-replica-lag", 10*time.Second, "How far behind the primary DB a replica can be and still serve the reads. Zero does not limit the lag.")
+	flag.IntVar(&CacheSize, "cache-size", 0, "Maximum number of book and collection listings kept in memory. Zero disables the cache.")
+	flag.DurationVar(&CacheTTL, "cache-ttl", time.Minute, "How long a listing is kept in the cache. Changes not made through this server show up only after it.")
+	flag.StringVar(&File, "sqlite-file", "book_management.db", "SQLite DB file. Used only with the sqlite driver.")
 }
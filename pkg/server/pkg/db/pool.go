@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// firstRetryDelay is the delay before the second attempt to reach the database, doubled after every failed attempt
+	firstRetryDelay = 100 * time.Millisecond
+	// maxRetryDelay bounds the delay between two attempts to reach the database
+	maxRetryDelay = 5 * time.Second
+)
+
+// connect applies the pool limits of the options to the database and waits until it is reachable. The database is closed if it never is.
+func connect(db *sql.DB, opts Options) (*sql.DB, error) {
+	configurePool(db, opts)
+
+	err := waitForDB(context.Background(), db, opts.WaitTimeout)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("wait for db at %v: %w", opts.Address(), err)
+	}
+	return db, nil
+}
+
+// configurePool applies the pool limits of the options to the database
+func configurePool(db *sql.DB, opts Options) {
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+}
+
+// waitForDB pings the database until it answers, waiting between the attempts with an exponential backoff.
+// It gives up once the timeout is over, zero meaning a single attempt, and returns ErrUnavailable together with the last failure.
+func waitForDB(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := firstRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx, db, deadline, timeout)
+		if err == nil {
+			return nil
+		}
+
+		if timeout == 0 || time.Until(deadline) <= delay || ctx.Err() != nil {
+			return fmt.Errorf("%w: no answer after %d attempt(s) in %v: %v", ErrUnavailable, attempt, timeout, err)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// ping checks that the database answers, giving up at the deadline unless the timeout is zero
+func ping(ctx context.Context, db *sql.DB, deadline time.Time, timeout time.Duration) error {
+	if timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
+}
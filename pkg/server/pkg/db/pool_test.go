@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakyConnector refuses the connections until it has been asked failures times
+type flakyConnector struct {
+	failures int
+	attempts int
+}
+
+func (c *flakyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.attempts++
+	if c.attempts <= c.failures {
+		return nil, errors.New("connection refused")
+	}
+	return flakyConn{}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver {
+	return nil
+}
+
+// flakyConn is a connection that is only pinged
+type flakyConn struct{}
+
+func (flakyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (flakyConn) Close() error {
+	return nil
+}
+
+func (flakyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestWaitForDB(t *testing.T) {
+	connector := &flakyConnector{failures: 3}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	err := waitForDB(context.Background(), db, 0)
+	require.ErrorIs(t, err, ErrUnavailable)
+	require.Contains(t, err.Error(), "connection refused")
+	require.Equal(t, 1, connector.attempts)
+
+	start := time.Now()
+	require.Nil(t, waitForDB(context.Background(), db, time.Minute))
+	require.Equal(t, 4, connector.attempts)
+	// the delays between the attempts are doubled: 100ms, then 200ms
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(300*time.Millisecond))
+
+	connector = &flakyConnector{failures: 100}
+	db = sql.OpenDB(connector)
+	defer db.Close()
+
+	err = waitForDB(context.Background(), db, 500*time.Millisecond)
+	require.ErrorIs(t, err, ErrUnavailable)
+	require.Equal(t, 3, connector.attempts)
+}
+
+func TestMySQLConfig(t *testing.T) {
+	config, err := mysqlConfig(Options{Host: "db.example", Port: "3306", User: "user", Pass: "p@ss/word", DB: "books",
+		DSNParams: "tls=true&timeout=5s&parseTime=false&charset=utf8mb4"})
+	require.Nil(t, err)
+	require.Equal(t, "db.example:3306", config.Addr)
+	require.Equal(t, "p@ss/word", config.Passwd)
+	require.Equal(t, "true", config.TLSConfig)
+	require.Equal(t, 5*time.Second, config.Timeout)
+	require.Equal(t, map[string]string{"charset": "utf8mb4"}, config.Params)
+	// the parameters the handler relies on are kept
+	require.True(t, config.ParseTime)
+	require.True(t, config.ClientFoundRows)
+
+	_, err = mysqlConfig(Options{Host: "db.example", Port: "3306", DSNParams: "timeout=soon"})
+	require.Error(t, err)
+}
+
+func TestPostgresDSN(t *testing.T) {
+	dsn, err := postgresDSN(Options{Host: "db.example", Port: "5432", User: "user", Pass: "it's", DB: "books",
+		DSNParams: "sslmode=require&connect_timeout=5"})
+	require.Nil(t, err)
+	require.Equal(t, `host='db.example' port='5432' user='user' password='it\'s' dbname='books' sslmode=disable connect_timeout='5' sslmode='require'`, dsn)
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PostgresHandler is the wrapper for the PostgreSQL database
+type PostgresHandler struct {
+	sqlHandler
+}
+
+// NewPostgresHandler returns a new PostgresHandler and set up the connection to the database and its replicas. It fails if the database schema is not up to date.
+func NewPostgresHandler(opts Options) (*PostgresHandler, error) {
+	db, err := openPostgres(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkSchema(db, apis.Postgres)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	replicas, err := openReplicas(opts, postgresConnector, postgresReplicaLag)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	handler := &PostgresHandler{}
+	handler.db = db
+	handler.dialect = apis.Postgres
+	handler.classify = classifyPostgresError
+	handler.stateColumn = postgresStateColumn
+	// the default read committed transactions take a snapshot per statement
+	handler.readOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	handler.timeout = opts.QueryTimeout
+	handler.replicas = replicas
+
+	return handler, nil
+}
+
+// openPostgres sets up the connection to the PostgreSQL database and waits until it is reachable
+func openPostgres(opts Options) (*sql.DB, error) {
+	connector, err := postgresConnector(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return connect(sql.OpenDB(connector), opts)
+}
+
+// postgresConnector returns the connector to the PostgreSQL database
+func postgresConnector(opts Options) (driver.Connector, error) {
+	dsn, err := postgresDSN(opts)
+	if err != nil {
+		return nil, fmt.Errorf("set up db connection: %v", err)
+	}
+
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("set up db connection: %v", err)
+	}
+	return connector, nil
+}
+
+// postgresDSN builds the connection string of the PostgreSQL database. The additional DSN parameters of the options come last, so that they override the defaults (e.g. sslmode).
+func postgresDSN(opts Options) (string, error) {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		quoteDSNValue(opts.Host), quoteDSNValue(opts.Port), quoteDSNValue(opts.User), quoteDSNValue(opts.Pass), quoteDSNValue(opts.DB))
+
+	params, err := url.ParseQuery(opts.DSNParams)
+	if err != nil {
+		return "", fmt.Errorf("invalid dsn params: %v", err)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		dsn += " " + key + "=" + quoteDSNValue(params.Get(key))
+	}
+	return dsn, nil
+}
+
+// quoteDSNValue quotes a value of a key/value PostgreSQL connection string
+func quoteDSNValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
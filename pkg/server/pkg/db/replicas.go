@@ -0,0 +1,357 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// replicaRetryDelay is how long a replica that failed to answer is left out before the reads try it again
+const replicaRetryDelay = 10 * time.Second
+
+// clientKey is the context key of the client performing the operations
+type clientKey struct{}
+
+// WithClient returns a copy of the context naming the client whose reads stick to the primary after its writes, see Options.ReadYourWrites.
+// The client must tell apart the callers sharing a principal, e.g. the anonymous ones.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// clientFrom returns the client named by the context, or its principal if there is none
+func clientFrom(ctx context.Context) string {
+	if client, ok := ctx.Value(clientKey{}).(string); ok && client != "" {
+		return client
+	}
+	return principalFrom(ctx)
+}
+
+// replica is a read-only copy of the primary database
+type replica struct {
+	db   *sql.DB
+	addr string
+	// downUntil is the time, in Unix nanoseconds, before which the replica is considered unreachable
+	downUntil int64
+	// lagging is 1 while the replica is too far behind the primary, or while its lag is not known yet
+	lagging int32
+}
+
+// healthy reports whether the replica is neither known to be unreachable nor lagging behind
+func (r *replica) healthy(now time.Time) bool {
+	return atomic.LoadInt64(&r.downUntil) <= now.UnixNano() && atomic.LoadInt32(&r.lagging) == 0
+}
+
+// fail leaves the replica out of the reads for a while
+func (r *replica) fail(now time.Time) {
+	atomic.StoreInt64(&r.downUntil, now.Add(replicaRetryDelay).UnixNano())
+}
+
+// checked records the outcome of a health check: the replica answered, and it lags behind or not
+func (r *replica) checked(lagging bool) {
+	atomic.StoreInt64(&r.downUntil, 0)
+
+	var flag int32
+	if lagging {
+		flag = 1
+	}
+	atomic.StoreInt32(&r.lagging, flag)
+}
+
+// replicaLagFunc returns how far the replica behind db is from the primary. It fails if the replica cannot tell, e.g. if its replication is stopped.
+type replicaLagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+// mysqlReplicaLag reads the delay reported by the replication status. SHOW SLAVE STATUS is kept for the servers older than MySQL 8.0.22.
+func mysqlReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1064 {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("replication is not configured")
+	}
+
+	values := make([]sql.NullString, len(columns))
+	targets := make([]interface{}, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err = rows.Scan(targets...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errors.New("replication is stopped")
+		}
+
+		seconds, err := strconv.ParseInt(values[i].String, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid replication delay %v: %v", values[i].String, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replication status does not report the delay")
+}
+
+// postgresReplicaLag computes the delay from the last replayed transaction. A replica that replayed all it received is up to date,
+// however old that transaction is, as the primary may simply not have been written to since.
+func postgresReplicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := db.QueryRowContext(ctx, `SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN NULL
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+
+	if !seconds.Valid {
+		return 0, errors.New("the database is not a replica")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+// replicaSet balances the reads across the healthy replicas. The nil replicaSet sends every read to the primary.
+// With a read-your-writes window, the reads of a client go to the primary for that long after each of its writes,
+// so that the client sees its changes even if the replicas lag behind.
+type replicaSet struct {
+	replicas []*replica
+	next     uint64
+	window   time.Duration
+	now      func() time.Time
+	// lag measures the lag of a replica for the health checks, which leave out the replicas more than maxLag behind. Zero maxLag only checks that they answer.
+	lag    replicaLagFunc
+	maxLag time.Duration
+
+	// done is closed to stop the health checks
+	done chan struct{}
+	stop sync.Once
+
+	mu sync.Mutex
+	// writes is the time of the last write of each client within the window
+	writes map[string]time.Time
+}
+
+// newReplicaSet returns the replicaSet of the databases
+func newReplicaSet(replicas []*replica, window time.Duration) *replicaSet {
+	return &replicaSet{
+		replicas: replicas,
+		window:   window,
+		now:      time.Now,
+		done:     make(chan struct{}),
+		writes:   make(map[string]time.Time),
+	}
+}
+
+// openReplicas sets up the connections to the replicas of the options, which share the credentials and the parameters of the primary.
+// Unlike the primary, the replicas are not waited for: the reads fall back to the primary until they answer, or with health checks until
+// the first check finds them close enough to the primary. It returns nil if there are no replicas.
+func openReplicas(opts Options, connector func(opts Options) (driver.Connector, error), lag replicaLagFunc) (*replicaSet, error) {
+	if len(opts.Replicas) == 0 {
+		return nil, nil
+	}
+
+	replicas := make([]*replica, 0, len(opts.Replicas))
+	for _, addr := range opts.Replicas {
+		replicaOpts := opts
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			host, port = addr, opts.Port
+		}
+		replicaOpts.Host, replicaOpts.Port = host, port
+
+		c, err := connector(replicaOpts)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("replica %v: %v", addr, err)
+		}
+
+		db := sql.OpenDB(c)
+		configurePool(db, opts)
+		replicas = append(replicas, &replica{db: db, addr: replicaOpts.Address()})
+	}
+
+	set := newReplicaSet(replicas, opts.ReadYourWrites)
+	set.lag = lag
+	set.maxLag = opts.MaxReplicaLag
+
+	if opts.ReplicaCheckInterval > 0 {
+		for _, r := range replicas {
+			r.lagging = 1
+		}
+		go set.monitor(opts.ReplicaCheckInterval)
+	}
+	return set, nil
+}
+
+// monitor checks the health of the replicas at every interval, until the replicaSet is closed
+func (r *replicaSet) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		r.check(ctx)
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// check measures the lag of every replica. The replicas that do not answer are left out as after a failed read,
+// those too far behind until a later check finds them close enough. The replicas are checked one after the other.
+func (r *replicaSet) check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		lag, err := r.lag(ctx, rep.db)
+		if err != nil {
+			rep.fail(r.now())
+			continue
+		}
+		rep.checked(r.maxLag > 0 && lag > r.maxLag)
+	}
+}
+
+// close stops the health checks and closes the connections to the replicas
+func (r *replicaSet) close() {
+	if r == nil {
+		return
+	}
+
+	r.stop.Do(func() { close(r.done) })
+	closeReplicas(r.replicas)
+}
+
+// closeReplicas closes the connections to the replicas
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		r.db.Close()
+	}
+}
+
+// pick returns the replica the next read should go to, in turn among the healthy ones. It returns nil if the read must go to the primary,
+// either because no replica is healthy or because the client of the context wrote within the read-your-writes window.
+func (r *replicaSet) pick(ctx context.Context) *replica {
+	if r == nil {
+		return nil
+	}
+
+	if r.sticky(ctx) {
+		return nil
+	}
+
+	now := r.now()
+	start := atomic.AddUint64(&r.next, 1)
+	for i := range r.replicas {
+		candidate := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if candidate.healthy(now) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// sticky reports whether the client of the context wrote within the read-your-writes window, so that its reads must go to the primary
+func (r *replicaSet) sticky(ctx context.Context) bool {
+	if r == nil || r.window <= 0 {
+		return false
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	last, wrote := r.writes[clientFrom(ctx)]
+	r.mu.Unlock()
+	return wrote && now.Sub(last) < r.window
+}
+
+// wrote records a write of the client of the context, so that its reads stick to the primary for the read-your-writes window
+func (r *replicaSet) wrote(ctx context.Context) {
+	if r == nil || r.window <= 0 {
+		return
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for client, last := range r.writes {
+		if now.Sub(last) >= r.window {
+			delete(r.writes, client)
+		}
+	}
+	r.writes[clientFrom(ctx)] = now
+}
+
+// read runs the queries of a read-only operation on a replica, if any is healthy. If the replica turns out to be unreachable,
+// it is left out for a while and the queries run again on the primary. run may thus be called twice and must not keep the results of a failed call.
+func (s *sqlHandler) read(ctx context.Context, run func(q queryer) error) error {
+	if r := s.replicas.pick(ctx); r != nil {
+		err := s.readTx(ctx, r.db, run)
+		if !errors.Is(err, ErrUnavailable) || ctx.Err() != nil {
+			return err
+		}
+		r.fail(s.replicas.now())
+	}
+	return s.readTx(ctx, s.db, run)
+}
+
+// readTx runs the queries of a read-only operation in a single transaction on db, so that they all see the same state of the database,
+// e.g. the total count of a listing and its page
+func (s *sqlHandler) readTx(ctx context.Context, db *sql.DB, run func(q queryer) error) error {
+	tx, err := db.BeginTx(ctx, s.readOptions)
+	if err != nil {
+		return s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if err = run(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.queryError(ctx, "commit transaction", err)
+	}
+	return nil
+}
+
+// readsOwnWrites reports whether the reads of the client of the context stick to the primary, since it wrote within the read-your-writes window
+func (s *sqlHandler) readsOwnWrites(ctx context.Context) bool {
+	return s.replicas.sticky(ctx)
+}
+
+// commit commits the transaction of a write. Whether it succeeds or not, the write is recorded for the read-your-writes window,
+// since a failed commit may still have been applied.
+func (s *sqlHandler) commit(ctx context.Context, tx *sql.Tx) error {
+	defer s.replicas.wrote(ctx)
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// downConnector is the connector of a database that never answers
+type downConnector struct{}
+
+func (downConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func (downConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestReplicas(t *testing.T) {
+	primary, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "primary.db")})
+	require.Nil(t, err)
+	defer primary.db.Close()
+
+	// the replica never receives the writes, as if it lagged behind forever
+	lagging, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "replica.db")})
+	require.Nil(t, err)
+	defer lagging.db.Close()
+
+	now := time.Now()
+	lag := &replica{db: lagging.db, addr: "replica"}
+	primary.replicas = newReplicaSet([]*replica{lag}, time.Minute)
+	primary.replicas.now = func() time.Time { return now }
+
+	// both clients are anonymous: the window is kept per client
+	alice := WithClient(context.Background(), "alice")
+	bob := WithClient(context.Background(), "bob")
+
+	_, err = primary.CreateBook(alice, &apis.Book{Isbn: "1"})
+	require.Nil(t, err)
+
+	total := func(ctx context.Context) int64 {
+		page, err := primary.GetBook(ctx, allBooks(t), apis.ListOptions{})
+		require.Nil(t, err)
+		return page.Total
+	}
+
+	// alice reads her writes from the primary, while bob reads from the replica
+	require.Equal(t, int64(1), total(alice))
+	require.Equal(t, int64(0), total(bob))
+
+	_, err = primary.History(bob, apis.BookType, "1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	now = now.Add(time.Minute)
+	require.Equal(t, int64(0), total(alice))
+
+	// the reads fall back to the primary when the replica does not answer, and leave it out for a while
+	lag.db = sql.OpenDB(downConnector{})
+	defer lag.db.Close()
+
+	require.Equal(t, int64(1), total(bob))
+	require.False(t, lag.healthy(now))
+	require.True(t, lag.healthy(now.Add(replicaRetryDelay)))
+}
+
+func TestPatchBookReadsThePrimary(t *testing.T) {
+	primary, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "primary.db")})
+	require.Nil(t, err)
+	defer primary.db.Close()
+
+	lagging, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "replica.db")})
+	require.Nil(t, err)
+	defer lagging.db.Close()
+
+	// without a read-your-writes window every read goes to the replica, which never receives the writes
+	primary.replicas = newReplicaSet([]*replica{{db: lagging.db, addr: "replica"}}, 0)
+
+	ctx := context.Background()
+	_, err = primary.CreateBook(ctx, &apis.Book{Isbn: "1", Title: "Inferno"})
+	require.Nil(t, err)
+	_, err = primary.UpdateBook(ctx, &apis.Book{Isbn: "1", Title: "Inferno", Author: "Dante"})
+	require.Nil(t, err)
+
+	setGenre := func(book *apis.Book) error {
+		book.Genre = "Poem"
+		return nil
+	}
+
+	_, _, err = primary.PatchBook(ctx, "1", 1, setGenre)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+
+	_, patched, err := primary.PatchBook(ctx, "1", 2, setGenre)
+	require.Nil(t, err)
+	require.Equal(t, apis.Book{Isbn: "1", Title: "Inferno", Author: "Dante", Genre: "Poem", Version: 3}, *patched)
+
+	_, _, err = primary.PatchBook(ctx, "1", 3, func(book *apis.Book) error {
+		book.Isbn = "2"
+		return nil
+	})
+	require.ErrorIs(t, err, ErrValidation)
+}
+
+func TestPickReplica(t *testing.T) {
+	replicas := []*replica{{addr: "first"}, {addr: "second"}}
+	set := newReplicaSet(replicas, 0)
+
+	ctx := context.Background()
+	first := set.pick(ctx)
+	second := set.pick(ctx)
+	require.NotEqual(t, first.addr, second.addr)
+	require.Equal(t, first, set.pick(ctx))
+
+	// without a read-your-writes window, the writes do not matter
+	set.wrote(ctx)
+	require.NotNil(t, set.pick(ctx))
+
+	for _, r := range replicas {
+		r.fail(time.Now())
+	}
+	require.Nil(t, set.pick(ctx))
+
+	var primaryOnly *replicaSet
+	require.Nil(t, primaryOnly.pick(ctx))
+}
+
+func TestCheckReplicas(t *testing.T) {
+	up, behind, down := &replica{addr: "up"}, &replica{addr: "behind"}, &replica{addr: "down"}
+	replicas := []*replica{up, behind, down}
+	lags := map[*sql.DB]time.Duration{}
+	for i, r := range replicas {
+		r.db = sql.OpenDB(downConnector{})
+		defer r.db.Close()
+		r.lagging = 1
+		lags[r.db] = time.Duration(i) * time.Minute
+	}
+
+	set := newReplicaSet(replicas, 0)
+	set.maxLag = 30 * time.Second
+	set.lag = func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+		if db == down.db {
+			return 0, ErrUnavailable
+		}
+		return lags[db], nil
+	}
+
+	// the replicas are left out until they are checked
+	ctx := context.Background()
+	require.Nil(t, set.pick(ctx))
+
+	set.check(ctx)
+	now := time.Now()
+	require.True(t, up.healthy(now))
+	require.False(t, behind.healthy(now))
+	require.False(t, down.healthy(now))
+	require.Equal(t, up, set.pick(ctx))
+	require.Equal(t, up, set.pick(ctx))
+
+	// a replica that catches up serves the reads again, as one that answers after a failed read
+	up.fail(now)
+	lags[behind.db] = time.Second
+	set.check(ctx)
+	require.True(t, up.healthy(now))
+	require.True(t, behind.healthy(now))
+
+	// closing the replicas stops the health checks
+	stopped := make(chan struct{})
+	go func() {
+		set.monitor(time.Millisecond)
+		close(stopped)
+	}()
+	set.close()
+	set.close()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the health checks did not stop")
+	}
+}
@@ -0,0 +1,1012 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// sqlHandler implements the Handler operations on top of database/sql. The driver specific handlers embed it and only take care of opening the connection.
+type sqlHandler struct {
+	db       *sql.DB
+	dialect  apis.Dialect
+	classify classifyFunc
+	// stateColumn reads the fields of the states recorded in the history as the columns of the tables
+	stateColumn stateColumnFunc
+	timeout     time.Duration
+	// readOptions are the options of the transactions of the reads, which must see a single snapshot of the database. Nil keeps the driver defaults.
+	readOptions *sql.TxOptions
+	// replicas serve the reads instead of db, nil if there are none
+	replicas *replicaSet
+}
+
+// Close stops the health checks of the replicas and closes the connections to the database and its replicas
+func (s *sqlHandler) Close() error {
+	s.replicas.close()
+	return s.db.Close()
+}
+
+// withTimeout bounds the context with the per-query deadline, if any. Without a deadline the context is returned as is:
+// cancelling a derived one right after the queries races with the SQLite driver, which may then interrupt the next query of the connection.
+func (s *sqlHandler) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// queryError converts the error of a query into the one returned to the caller.
+// Deadline and cancellation errors are propagated, driver errors are turned into the Handler errors and anything else is logged and hidden.
+func (s *sqlHandler) queryError(ctx context.Context, op string, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+
+	if kind := s.classify(err); kind != nil {
+		return fmt.Errorf("%w: %s", kind, driverMessage(err))
+	}
+
+	log.Printf("%s: %v", op, err)
+	return fmt.Errorf("%w: %s", ErrInternal, op)
+}
+
+// savepoint runs exec within a savepoint so that, if exec fails, the transaction can go on without its changes.
+// execErr is the error returned by exec, while err reports a failure of the transaction itself.
+func (s *sqlHandler) savepoint(ctx context.Context, tx *sql.Tx, exec func() error) (execErr error, err error) {
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT sp_statement"); err != nil {
+		return nil, s.queryError(ctx, "create savepoint", err)
+	}
+
+	if execErr = exec(); execErr != nil {
+		if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT sp_statement"); err != nil {
+			return nil, s.queryError(ctx, "rollback to savepoint", err)
+		}
+		return execErr, nil
+	}
+
+	if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT sp_statement"); err != nil {
+		return nil, s.queryError(ctx, "release savepoint", err)
+	}
+	return nil, nil
+}
+
+// CreateBook creates a new book in the database
+func (s *sqlHandler) CreateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	if err = validateBook(book); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, bookTable.insertStatement(s.dialect), bookTable.insertValues(book)...)
+	if err != nil {
+		err = s.queryError(ctx, "execute statement", err)
+		if errors.Is(err, ErrConflict) {
+			return "", fmt.Errorf("%w: book with ISBN %v already exists", ErrConflict, book.Isbn)
+		}
+		return "", err
+	}
+
+	if _, err = s.recordChange(ctx, tx, bookTable, apis.HistoryCreate, book.Isbn, nil); err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+	book.Version = 1
+
+	return fmt.Sprintf("Created book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+}
+
+// UpdateBook updates an existing book in the database. It returns ErrNotFound if the book does not exist.
+// If book.Version is set, the book is only updated if it is still at that version, otherwise ErrPreconditionFailed is returned.
+// On success book.Version holds the new version.
+func (s *sqlHandler) UpdateBook(ctx context.Context, book *apis.Book) (message string, err error) {
+	if err = validateBook(book); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, bookTable, book.Isbn, apis.ExcludeDeleted)
+	if err != nil {
+		return "", err
+	}
+
+	if before == nil {
+		return "", fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, book.Isbn)
+	}
+
+	if err = s.updateRow(ctx, tx, book, before.(*apis.Book)); err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), nil
+}
+
+// PatchBook reads the book in the transaction of the update, so that the changes of patch are merged into the book as stored on the primary.
+// It returns ErrNotFound if the book does not exist and ErrPreconditionFailed if it is not at version.
+func (s *sqlHandler) PatchBook(ctx context.Context, isbn string, version uint64, patch func(book *apis.Book) error) (message string, patched *apis.Book, err error) {
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", nil, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", nil, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, bookTable, isbn, apis.ExcludeDeleted)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if before == nil {
+		return "", nil, fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, isbn)
+	}
+
+	book, err := patchBook(*before.(*apis.Book), version, patch)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err = s.updateRow(ctx, tx, &book, before.(*apis.Book)); err != nil {
+		return "", nil, err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", nil, s.queryError(ctx, "commit transaction", err)
+	}
+
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), &book, nil
+}
+
+// patchBook applies patch to a copy of the book as stored, which must be at version, and returns the book to write back.
+// Merging into another version would write back its fields, so the version is checked before the book is patched too.
+func patchBook(stored apis.Book, version uint64, patch func(book *apis.Book) error) (apis.Book, error) {
+	if stored.Version != version {
+		return apis.Book{}, fmt.Errorf("%w: book with ISBN %v is at version %d", ErrPreconditionFailed, stored.Isbn, stored.Version)
+	}
+
+	book := stored
+	if err := patch(&book); err != nil {
+		return apis.Book{}, err
+	}
+
+	if isbnKey(book.Isbn) != isbnKey(stored.Isbn) {
+		return apis.Book{}, fmt.Errorf("%w: the ISBN of a book cannot be changed", ErrValidation)
+	}
+	if err := validateBook(&book); err != nil {
+		return apis.Book{}, err
+	}
+
+	book.Version = version
+	book.DeletedAt = nil
+	return book, nil
+}
+
+// updateRow updates the book read as before within tx and records the change. If book.Version is set, the book is only updated if it is still at that version.
+// On success book.Version holds the new version.
+func (s *sqlHandler) updateRow(ctx context.Context, tx *sql.Tx, book *apis.Book, before *apis.Book) error {
+	checkVersion := book.Version != 0
+	result, err := tx.ExecContext(ctx, bookTable.updateStatement(s.dialect, checkVersion), bookTable.updateValues(book, checkVersion)...)
+	if err != nil {
+		return s.queryError(ctx, "execute statement", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, "read affected rows", err)
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("%w: book with ISBN %v is at version %d", ErrPreconditionFailed, book.Isbn, before.Version)
+	}
+
+	after, err := s.recordChange(ctx, tx, bookTable, apis.HistoryUpdate, book.Isbn, before)
+	if err != nil {
+		return err
+	}
+
+	book.Version = after.(*apis.Book).Version
+	return nil
+}
+
+// ApplyBook updates the book in the database or creates it if it does not exist yet, regardless of its version. On success book.Version holds the new version.
+func (s *sqlHandler) ApplyBook(ctx context.Context, book *apis.Book) (message string, created bool, err error) {
+	if err = validateBook(book); err != nil {
+		return "", false, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", false, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	update := func() (int64, error) {
+		result, err := tx.ExecContext(ctx, bookTable.updateStatement(s.dialect, false), bookTable.updateValues(book, false)...)
+		if err != nil {
+			return 0, s.queryError(ctx, "execute statement", err)
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return 0, s.queryError(ctx, "read affected rows", err)
+		}
+		return updated, nil
+	}
+
+	before, err := s.readRow(ctx, tx, bookTable, book.Isbn, apis.ExcludeDeleted)
+	if err != nil {
+		return "", false, err
+	}
+
+	updated, err := update()
+	if err != nil {
+		return "", false, err
+	}
+
+	if updated == 0 {
+		insertErr, err := s.savepoint(ctx, tx, func() error {
+			_, err := tx.ExecContext(ctx, bookTable.insertStatement(s.dialect), bookTable.insertValues(book)...)
+			return err
+		})
+
+		if err != nil {
+			return "", false, err
+		}
+
+		created = insertErr == nil
+		if insertErr != nil {
+			insertErr = s.queryError(ctx, "execute statement", insertErr)
+			if !errors.Is(insertErr, ErrConflict) {
+				return "", false, insertErr
+			}
+
+			// the book has been created in the meantime, unless it is in the trash
+			if before, err = s.readRow(ctx, tx, bookTable, book.Isbn, apis.ExcludeDeleted); err != nil {
+				return "", false, err
+			}
+
+			updated, err = update()
+			if err != nil {
+				return "", false, err
+			}
+			if updated == 0 {
+				return "", false, fmt.Errorf("%w: book with ISBN %v is in the trash", ErrConflict, book.Isbn)
+			}
+		}
+	}
+
+	op := apis.HistoryUpdate
+	if created {
+		op = apis.HistoryCreate
+	}
+
+	after, err := s.recordChange(ctx, tx, bookTable, op, book.Isbn, before)
+	if err != nil {
+		return "", false, err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", false, s.queryError(ctx, "commit transaction", err)
+	}
+	book.Version = after.(*apis.Book).Version
+
+	if created {
+		return fmt.Sprintf("Created book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), true, nil
+	}
+	return fmt.Sprintf("Updated book %v written by %v with ISBN: %v", book.Title, book.Author, book.Isbn), false, nil
+}
+
+// GetBook returns a page of the books matching the supplied filters together with their total count. It reads from a replica if any.
+func (s *sqlHandler) GetBook(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.BookPage, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err = s.read(ctx, func(q queryer) (err error) {
+		page, err = s.getBook(ctx, q, filters, opts)
+		return err
+	})
+	return page, err
+}
+
+// getBook reads the page of the books with q
+func (s *sqlHandler) getBook(ctx context.Context, q queryer, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.BookPage, err error) {
+	if !opts.AsOf.IsZero() {
+		return s.getBookAsOf(ctx, q, filters, opts)
+	}
+
+	page = &apis.BookPage{Items: []apis.Book{}}
+
+	total, more, err := s.listRows(ctx, q, bookTable, filters, opts, func(rows *sql.Rows) error {
+		book := apis.Book{}
+		if err := rows.Scan(bookTable.scanTargets(&book)...); err != nil {
+			return err
+		}
+		page.Items = append(page.Items, book)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	page.Total = total
+	if more && opts.KeysetOrdered(bookTable.keyField) {
+		page.NextCursor = page.Items[len(page.Items)-1].Isbn
+	}
+
+	return page, nil
+}
+
+// listRows counts the rows of the table matching the filters and calls scan on each row of the page selected by the options.
+// It returns the total count and whether more rows follow the page. The count only agrees with the page if q is a transaction, see readTx.
+func (s *sqlHandler) listRows(ctx context.Context, q queryer, m *tableMapping, filters *apis.FilterChain, opts apis.ListOptions, scan func(rows *sql.Rows) error) (total int64, more bool, err error) {
+	return s.listFrom(ctx, q, m, m.table, m.selectList(), nil, filters, opts, scan)
+}
+
+// listFrom lists as listRows the rows of from, a table or a derived table with the columns of m whose placeholders take args, selecting the given columns
+func (s *sqlHandler) listFrom(ctx context.Context, q queryer, m *tableMapping, from string, columns string, args []interface{}, filters *apis.FilterChain, opts apis.ListOptions, scan func(rows *sql.Rows) error) (total int64, more bool, err error) {
+	where, query := filters.DialectSQLStatement(s.dialect, len(args))
+	where = m.visible(where, opts.Deleted)
+	query = append(append([]interface{}{}, args...), query...)
+
+	err = q.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", from, where), query...).Scan(&total)
+	if err != nil {
+		return 0, false, s.queryError(ctx, "count rows", err)
+	}
+
+	if opts.Cursor != "" {
+		where = fmt.Sprintf("(%s) AND %s > %s", where, m.key, s.dialect.Placeholder(len(query)+1))
+		query = append(query, opts.Cursor)
+	}
+
+	// one more row tells whether a next page exists
+	limit := opts.Limit
+	if limit > 0 {
+		limit++
+	}
+
+	qs := strings.TrimSpace(fmt.Sprintf("SELECT %s FROM %s WHERE %s %s %s", columns, from, where, m.orderBy(opts.Sort), s.dialect.LimitOffset(limit, opts.Offset)))
+
+	rows, err := q.QueryContext(ctx, qs, query...)
+	if err != nil {
+		return 0, false, s.queryError(ctx, "execute statement", err)
+	}
+	defer rows.Close()
+
+	for read := 0; rows.Next(); read++ {
+		if opts.Limit > 0 && read == opts.Limit {
+			more = true
+			break
+		}
+
+		if err = scan(rows); err != nil {
+			return 0, false, s.queryError(ctx, "scan row", err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, false, s.queryError(ctx, "read rows", err)
+	}
+
+	return total, more, nil
+}
+
+// DeleteBook moves the books matching the supplied filters to the trash and returns how many were deleted.
+// Their memberships are kept, but hidden, until the books are restored or purged.
+func (s *sqlHandler) DeleteBook(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	return s.trashRows(ctx, bookTable, filters, version)
+}
+
+// trashRows moves the rows matching the filters to the trash, incrementing their version, and returns how many were moved.
+// A non-zero version is checked by the update itself, on the primary, and requires the filters to match a single row.
+func (s *sqlHandler) trashRows(ctx context.Context, m *tableMapping, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	where, query := filters.DialectSQLStatement(s.dialect, 0)
+	live, err := s.readRows(ctx, tx, m, m.visible(where, apis.ExcludeDeleted), query...)
+	if err != nil {
+		return 0, err
+	}
+
+	if version != 0 {
+		switch {
+		case len(live) == 0:
+			return 0, fmt.Errorf("%w: no %v matches the filters", ErrNotFound, m.kind)
+		case len(live) > 1:
+			return 0, fmt.Errorf("%w: a conditional delete requires the filters to match a single %v, %d matched", ErrAmbiguous, m.kind, len(live))
+		}
+	}
+
+	// the rows are moved one by one, so that each change is recorded in the history
+	where = m.key + " = " + s.dialect.Placeholder(2)
+	if version != 0 {
+		where += fmt.Sprintf(" AND %s = %s", m.version, s.dialect.Placeholder(3))
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("UPDATE %s SET %s = %s, %s = %s + 1 WHERE %s", m.table, m.deleted, s.dialect.Placeholder(1), m.version, m.version, m.visible(where, apis.ExcludeDeleted)))
+	if err != nil {
+		return 0, s.queryError(ctx, "prepare statement", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, before := range live {
+		key := m.keyOf(before)
+		args := []interface{}{now, key}
+		if version != 0 {
+			args = append(args, version)
+		}
+
+		result, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return 0, s.queryError(ctx, "execute statement", err)
+		}
+
+		trashed, err := result.RowsAffected()
+		if err != nil {
+			return 0, s.queryError(ctx, "read affected rows", err)
+		}
+
+		if trashed == 0 && version != 0 {
+			return 0, fmt.Errorf("%w: %v %v is not at version %d", ErrPreconditionFailed, m.kind, key, version)
+		}
+
+		// moved to the trash in the meantime
+		if trashed == 0 {
+			continue
+		}
+
+		if _, err = s.recordChange(ctx, tx, m, apis.HistoryDelete, key, before); err != nil {
+			return 0, err
+		}
+		deleted++
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return 0, s.queryError(ctx, "commit transaction", err)
+	}
+
+	return deleted, nil
+}
+
+// RestoreBook moves a book out of the trash
+func (s *sqlHandler) RestoreBook(ctx context.Context, isbn string) (message string, err error) {
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	if err = s.restoreRow(ctx, bookTable, isbn); err != nil {
+		return "", fmt.Errorf("%w: book with ISBN %v is not in the trash", err, isbn)
+	}
+	return fmt.Sprintf("Restored book with ISBN %v", isbn), nil
+}
+
+// restoreRow moves the row identified by key out of the trash, incrementing its version. It returns ErrNotFound if the row is not in the trash.
+func (s *sqlHandler) restoreRow(ctx context.Context, m *tableMapping, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, m, key, apis.OnlyDeleted)
+	if err != nil {
+		return err
+	}
+
+	if before == nil {
+		return ErrNotFound
+	}
+
+	where := m.visible(m.key+" = "+s.dialect.Placeholder(1), apis.OnlyDeleted)
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = NULL, %s = %s + 1 WHERE %s", m.table, m.deleted, m.version, m.version, where), key)
+	if err != nil {
+		return s.queryError(ctx, "execute statement", err)
+	}
+
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, "read affected rows", err)
+	}
+
+	if restored == 0 {
+		return ErrNotFound
+	}
+
+	if _, err = s.recordChange(ctx, tx, m, apis.HistoryRestore, key, before); err != nil {
+		return err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return s.queryError(ctx, "commit transaction", err)
+	}
+	return nil
+}
+
+// purgeBatchSize is the number of rows removed by each transaction of a purge
+var purgeBatchSize = 500
+
+// Purge permanently removes the books and the collections moved to the trash before the given time. The memberships are removed by the database.
+// The rows are removed in batches, each one committed on its own within the query timeout, so that purging a large trash makes progress
+// however long it takes. If a batch fails, the ones before it stay purged and are counted in the results returned with the error.
+func (s *sqlHandler) Purge(ctx context.Context, before time.Time) (books int64, collections int64, err error) {
+	for _, table := range []struct {
+		m      *tableMapping
+		purged *int64
+	}{{bookTable, &books}, {collectionTable, &collections}} {
+		for {
+			read, purged, err := s.purgeBatch(ctx, table.m, before)
+			*table.purged += purged
+			if err != nil {
+				return books, collections, err
+			}
+			if read < purgeBatchSize {
+				break
+			}
+		}
+	}
+
+	return books, collections, nil
+}
+
+// purgeBatch removes up to purgeBatchSize rows of the table moved to the trash before the given time, in a single transaction.
+// It returns the number of rows read from the trash, and of the ones removed, which may be fewer if some were restored meanwhile.
+func (s *sqlHandler) purgeBatch(ctx context.Context, m *tableMapping, before time.Time) (read int, purged int64, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	trash, err := s.readRows(ctx, tx, m, fmt.Sprintf("%s < %s ORDER BY %s LIMIT %d", m.deleted, s.dialect.Placeholder(1), m.key, purgeBatchSize), before.UTC())
+	if err != nil || len(trash) == 0 {
+		return 0, 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", m.table, m.visible(m.key+" = "+s.dialect.Placeholder(1), apis.OnlyDeleted)))
+	if err != nil {
+		return 0, 0, s.queryError(ctx, "prepare statement", err)
+	}
+	defer stmt.Close()
+
+	// the rows are removed one by one, so that each removal is recorded in the history
+	for _, trashed := range trash {
+		key := m.keyOf(trashed)
+		result, err := stmt.ExecContext(ctx, key)
+		if err != nil {
+			return 0, 0, s.queryError(ctx, "execute statement", err)
+		}
+
+		removed, err := result.RowsAffected()
+		if err != nil {
+			return 0, 0, s.queryError(ctx, "read affected rows", err)
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		if err = s.record(ctx, tx, m, apis.HistoryPurge, key, trashed, nil); err != nil {
+			return 0, 0, err
+		}
+		purged++
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return 0, 0, s.queryError(ctx, "commit transaction", err)
+	}
+
+	return len(trash), purged, nil
+}
+
+// CreateCollection creates a new collection and its members in the database
+func (s *sqlHandler) CreateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	if err = validateCollection(collection); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, collectionTable.insertStatement(s.dialect), collectionTable.insertValues(collection)...)
+	if err != nil {
+		err = s.queryError(ctx, "execute statement", err)
+		if errors.Is(err, ErrConflict) {
+			return "", fmt.Errorf("%w: collection %v already exists", ErrConflict, collection.Name)
+		}
+		return "", err
+	}
+
+	if err = s.insertMembers(ctx, tx, collection); err != nil {
+		return "", err
+	}
+
+	if _, err = s.recordChange(ctx, tx, collectionTable, apis.HistoryCreate, collection.Name, nil); err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+	collection.Version = 1
+
+	return fmt.Sprintf("Created collection %v with %d book(s)", collection.Name, len(collection.Books)), nil
+}
+
+// UpdateCollection updates an existing collection in the database and replaces its members.
+// As for UpdateBook, collection.Version is the expected version, if set, and holds the new version on success.
+func (s *sqlHandler) UpdateCollection(ctx context.Context, collection *apis.Collection) (message string, err error) {
+	if err = validateCollection(collection); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, collectionTable, collection.Name, apis.ExcludeDeleted)
+	if err != nil {
+		return "", err
+	}
+
+	if before == nil {
+		return "", fmt.Errorf("%w: collection %v does not exist", ErrNotFound, collection.Name)
+	}
+
+	checkVersion := collection.Version != 0
+	result, err := tx.ExecContext(ctx, collectionTable.updateStatement(s.dialect, checkVersion), collectionTable.updateValues(collection, checkVersion)...)
+	if err != nil {
+		return "", s.queryError(ctx, "execute statement", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return "", s.queryError(ctx, "read affected rows", err)
+	}
+
+	if updated == 0 {
+		return "", fmt.Errorf("%w: collection %v is at version %d", ErrPreconditionFailed, collection.Name, before.(*apis.Collection).Version)
+	}
+
+	// the memberships of the books in the trash are kept, so that restoring a book brings it back to its collections
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM collection_members WHERE collection_name = %s AND book_isbn NOT IN (SELECT isbn FROM books WHERE deleted_at IS NOT NULL)", s.dialect.Placeholder(1)), collection.Name)
+	if err != nil {
+		return "", s.queryError(ctx, "execute statement", err)
+	}
+
+	if err = s.insertMembers(ctx, tx, collection); err != nil {
+		return "", err
+	}
+
+	after, err := s.recordChange(ctx, tx, collectionTable, apis.HistoryUpdate, collection.Name, before)
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+	collection.Version = after.(*apis.Collection).Version
+
+	return fmt.Sprintf("Updated collection %v with %d book(s)", collection.Name, len(collection.Books)), nil
+}
+
+// insertMembers stores the books of the collection in the collection_members table
+func (s *sqlHandler) insertMembers(ctx context.Context, tx *sql.Tx, collection *apis.Collection) error {
+	if len(collection.Books) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, s.insertMemberStatement())
+	if err != nil {
+		return s.queryError(ctx, "prepare statement", err)
+	}
+	defer stmt.Close()
+
+	for _, isbn := range collection.Books {
+		result, err := stmt.ExecContext(ctx, collection.Name, isbn)
+		if err != nil {
+			return s.queryError(ctx, "execute statement", err)
+		}
+
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return s.queryError(ctx, "read affected rows", err)
+		}
+
+		if inserted == 0 {
+			return fmt.Errorf("%w: book with ISBN %v does not exist", ErrValidation, isbn)
+		}
+	}
+	return nil
+}
+
+// insertMemberStatement returns the statement adding a book, bound by ISBN, to a collection, bound by name.
+// Nothing is inserted if the book or the collection does not exist or is in the trash.
+func (s *sqlHandler) insertMemberStatement() string {
+	return fmt.Sprintf("INSERT INTO collection_members (collection_name, book_isbn) SELECT c.name, b.isbn FROM collections c, books b WHERE c.name = %s AND b.isbn = %s AND c.deleted_at IS NULL AND b.deleted_at IS NULL",
+		s.dialect.Placeholder(1), s.dialect.Placeholder(2))
+}
+
+// GetCollection returns a page of the collections matching the supplied filters together with their total count. It reads from a replica if any.
+func (s *sqlHandler) GetCollection(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.CollectionPage, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	err = s.read(ctx, func(q queryer) (err error) {
+		page, err = s.getCollection(ctx, q, filters, opts)
+		return err
+	})
+	return page, err
+}
+
+// getCollection reads the page of the collections with q
+func (s *sqlHandler) getCollection(ctx context.Context, q queryer, filters *apis.FilterChain, opts apis.ListOptions) (page *apis.CollectionPage, err error) {
+	if !opts.AsOf.IsZero() {
+		return s.getCollectionAsOf(ctx, q, filters, opts)
+	}
+
+	page = &apis.CollectionPage{Items: []apis.Collection{}}
+
+	total, more, err := s.listRows(ctx, q, collectionTable, filters, opts, func(rows *sql.Rows) error {
+		collection := apis.Collection{Books: []string{}}
+		if err := rows.Scan(collectionTable.scanTargets(&collection)...); err != nil {
+			return err
+		}
+		page.Items = append(page.Items, collection)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]*apis.Collection, len(page.Items))
+	for i := range page.Items {
+		collections[i] = &page.Items[i]
+	}
+
+	if err = s.readMembers(ctx, q, collections); err != nil {
+		return nil, err
+	}
+
+	page.Total = total
+	if more && opts.KeysetOrdered(collectionTable.keyField) {
+		page.NextCursor = page.Items[len(page.Items)-1].Name
+	}
+
+	return page, nil
+}
+
+// readMembers fills the books of the collections reading the collection_members table. The books in the trash are left out.
+func (s *sqlHandler) readMembers(ctx context.Context, q queryer, collections []*apis.Collection) error {
+	if len(collections) == 0 {
+		return nil
+	}
+
+	// names are compared ignoring case as the database does
+	byName := make(map[string]*apis.Collection, len(collections))
+	names := make([]interface{}, len(collections))
+	for i, collection := range collections {
+		byName[strings.ToLower(collection.Name)] = collection
+		names[i] = collection.Name
+	}
+
+	qs := fmt.Sprintf("SELECT m.collection_name, m.book_isbn FROM collection_members m JOIN books b ON b.isbn = m.book_isbn WHERE m.collection_name IN (%s) AND b.deleted_at IS NULL ORDER BY m.book_isbn",
+		apis.Placeholders(s.dialect, 0, len(names)))
+
+	rows, err := q.QueryContext(ctx, qs, names...)
+	if err != nil {
+		return s.queryError(ctx, "execute statement", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, isbn string
+		if err = rows.Scan(&name, &isbn); err != nil {
+			return s.queryError(ctx, "scan row", err)
+		}
+
+		if collection, ok := byName[strings.ToLower(name)]; ok {
+			collection.Books = append(collection.Books, isbn)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return s.queryError(ctx, "read rows", err)
+	}
+	return nil
+}
+
+// DeleteCollection moves the collections matching the supplied filters to the trash and returns how many were deleted
+func (s *sqlHandler) DeleteCollection(ctx context.Context, filters *apis.FilterChain, version uint64) (deleted int64, err error) {
+	return s.trashRows(ctx, collectionTable, filters, version)
+}
+
+// RestoreCollection moves a collection out of the trash together with its members
+func (s *sqlHandler) RestoreCollection(ctx context.Context, name string) (message string, err error) {
+	if err = s.restoreRow(ctx, collectionTable, name); err != nil {
+		return "", fmt.Errorf("%w: collection %v is not in the trash", err, name)
+	}
+	return fmt.Sprintf("Restored collection %v", name), nil
+}
+
+// AddBookToCollection adds an existing book to an existing collection
+func (s *sqlHandler) AddBookToCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, collectionTable, name, apis.ExcludeDeleted)
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.touchCollection(ctx, tx, name); err != nil {
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx, s.insertMemberStatement(), name, isbn)
+	if err != nil {
+		err = s.queryError(ctx, "execute statement", err)
+		if errors.Is(err, ErrConflict) {
+			return "", fmt.Errorf("%w: book with ISBN %v is already in collection %v", ErrConflict, isbn, name)
+		}
+		return "", err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return "", s.queryError(ctx, "read affected rows", err)
+	}
+
+	if inserted == 0 {
+		return "", fmt.Errorf("%w: book with ISBN %v does not exist", ErrNotFound, isbn)
+	}
+
+	if _, err = s.recordChange(ctx, tx, collectionTable, apis.HistoryUpdate, name, before); err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+
+	return fmt.Sprintf("Added book with ISBN %v to collection %v", isbn, name), nil
+}
+
+// RemoveBookFromCollection removes a book from a collection
+func (s *sqlHandler) RemoveBookFromCollection(ctx context.Context, name string, isbn string) (message string, err error) {
+	if err = validateBook(&apis.Book{Isbn: isbn}); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", s.queryError(ctx, "begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	before, err := s.readRow(ctx, tx, collectionTable, name, apis.ExcludeDeleted)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM collection_members WHERE collection_name = %s AND book_isbn = %s", s.dialect.Placeholder(1), s.dialect.Placeholder(2)), name, isbn)
+	if err != nil {
+		return "", s.queryError(ctx, "execute statement", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return "", s.queryError(ctx, "read affected rows", err)
+	}
+
+	if removed == 0 {
+		return "", fmt.Errorf("%w: book with ISBN %v is not in collection %v", ErrNotFound, isbn, name)
+	}
+
+	if err = s.touchCollection(ctx, tx, name); err != nil {
+		return "", err
+	}
+
+	if _, err = s.recordChange(ctx, tx, collectionTable, apis.HistoryUpdate, name, before); err != nil {
+		return "", err
+	}
+
+	if err = s.commit(ctx, tx); err != nil {
+		return "", s.queryError(ctx, "commit transaction", err)
+	}
+
+	return fmt.Sprintf("Removed book with ISBN %v from collection %v", isbn, name), nil
+}
+
+// touchCollection increments the version of a collection whose members changed. It returns ErrNotFound if the collection does not exist or is in the trash.
+func (s *sqlHandler) touchCollection(ctx context.Context, tx *sql.Tx, name string) error {
+	m := collectionTable
+	where := m.visible(m.key+" = "+s.dialect.Placeholder(1), apis.ExcludeDeleted)
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s = %s + 1 WHERE %s", m.table, m.version, m.version, where), name)
+	if err != nil {
+		return s.queryError(ctx, "execute statement", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return s.queryError(ctx, "read affected rows", err)
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("%w: collection %v does not exist", ErrNotFound, name)
+	}
+	return nil
+}
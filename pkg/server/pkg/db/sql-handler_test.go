@@ -0,0 +1,519 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateAndApplyBook(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	book := &apis.Book{Isbn: "1234567890987", Title: "Romeo and Juliet"}
+
+	_, err = handler.UpdateBook(ctx, book)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, created, err := handler.ApplyBook(ctx, book)
+	require.Nil(t, err)
+	require.True(t, created)
+
+	// an update leaving the book unchanged still matches it
+	_, err = handler.UpdateBook(ctx, book)
+	require.Nil(t, err)
+
+	book.Title = "Hamlet"
+	_, created, err = handler.ApplyBook(ctx, book)
+	require.Nil(t, err)
+	require.False(t, created)
+
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "Hamlet", page.Items[0].Title)
+}
+
+func TestBookVersions(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	book := &apis.Book{Isbn: "1234567890987", Title: "Romeo and Juliet", Version: 7}
+
+	_, err = handler.CreateBook(ctx, book)
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), book.Version)
+
+	stale := *book
+	_, err = handler.UpdateBook(ctx, book)
+	require.Nil(t, err)
+	require.Equal(t, uint64(2), book.Version)
+
+	_, err = handler.UpdateBook(ctx, &stale)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+
+	// without a version the update is unconditional
+	stale.Version = 0
+	_, err = handler.UpdateBook(ctx, &stale)
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), stale.Version)
+
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), page.Items[0].Version)
+
+	collection := &apis.Collection{Name: "Classics", Books: []string{book.Isbn}}
+	_, err = handler.CreateCollection(ctx, collection)
+	require.Nil(t, err)
+
+	_, err = handler.RemoveBookFromCollection(ctx, "classics", book.Isbn)
+	require.Nil(t, err)
+
+	_, err = handler.UpdateCollection(ctx, collection)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+
+	collection.Version = 2
+	_, err = handler.UpdateCollection(ctx, collection)
+	require.Nil(t, err)
+	require.Equal(t, uint64(3), collection.Version)
+
+	names, err := apis.ParseFilters("name_eq_classics", apis.ValidateCollectionField, apis.ValidateCollectionValue)
+	require.Nil(t, err)
+
+	_, err = handler.DeleteCollection(ctx, names, 2)
+	require.ErrorIs(t, err, ErrPreconditionFailed)
+
+	deleted, err := handler.DeleteCollection(ctx, names, 3)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	_, err = handler.DeleteCollection(ctx, names, 4)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = handler.CreateBook(ctx, &apis.Book{Isbn: "1", Title: "Hamlet"})
+	require.Nil(t, err)
+
+	_, err = handler.DeleteBook(ctx, allBooks(t), 1)
+	require.ErrorIs(t, err, ErrAmbiguous)
+}
+
+func TestTrash(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	for _, isbn := range []string{"1", "2"} {
+		_, err = handler.CreateBook(ctx, &apis.Book{Isbn: isbn, Title: "Hamlet"})
+		require.Nil(t, err)
+	}
+
+	_, err = handler.CreateCollection(ctx, &apis.Collection{Name: "Classics", Books: []string{"1", "2"}})
+	require.Nil(t, err)
+
+	isbn1, err := apis.ParseFilters("isbn_eq_1", apis.ValidateBookField, apis.ValidateBookValue)
+	require.Nil(t, err)
+
+	deleted, err := handler.DeleteBook(ctx, isbn1, 0)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	// books in the trash are hidden, as their memberships
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "2", page.Items[0].Isbn)
+
+	page, err = handler.GetBook(ctx, allBooks(t), apis.ListOptions{Deleted: apis.OnlyDeleted})
+	require.Nil(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "1", page.Items[0].Isbn)
+	require.NotNil(t, page.Items[0].DeletedAt)
+	require.WithinDuration(t, time.Now(), *page.Items[0].DeletedAt, time.Minute)
+
+	collections, err := handler.GetCollection(ctx, allCollections(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"2"}, collections.Items[0].Books)
+
+	_, err = handler.UpdateBook(ctx, &apis.Book{Isbn: "1", Title: "Macbeth"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = handler.AddBookToCollection(ctx, "Classics", "1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = handler.CreateBook(ctx, &apis.Book{Isbn: "1"})
+	require.ErrorIs(t, err, ErrConflict)
+
+	// restoring the book brings it back to its collections
+	_, err = handler.RestoreBook(ctx, "1")
+	require.Nil(t, err)
+
+	_, err = handler.RestoreBook(ctx, "1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	collections, err = handler.GetCollection(ctx, allCollections(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"1", "2"}, collections.Items[0].Books)
+
+	_, err = handler.DeleteBook(ctx, isbn1, 0)
+	require.Nil(t, err)
+
+	books, purged, err := handler.Purge(ctx, time.Now().Add(-time.Hour))
+	require.Nil(t, err)
+	require.Equal(t, int64(0), books+purged)
+
+	books, purged, err = handler.Purge(ctx, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Equal(t, int64(1), books)
+	require.Equal(t, int64(0), purged)
+
+	page, err = handler.GetBook(ctx, allBooks(t), apis.ListOptions{Deleted: apis.IncludeDeleted})
+	require.Nil(t, err)
+	require.Len(t, page.Items, 1)
+
+	// the book can be created again once purged
+	_, err = handler.CreateBook(ctx, &apis.Book{Isbn: "1"})
+	require.Nil(t, err)
+
+	collections, err = handler.GetCollection(ctx, allCollections(t), apis.ListOptions{})
+	require.Nil(t, err)
+	require.Equal(t, []string{"2"}, collections.Items[0].Books)
+}
+
+func TestPurgeBatches(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	defer func(size int) { purgeBatchSize = size }(purgeBatchSize)
+	purgeBatchSize = 2
+
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		_, err = handler.CreateBook(ctx, &apis.Book{Isbn: strconv.Itoa(i)})
+		require.Nil(t, err)
+	}
+	_, err = handler.DeleteBook(ctx, allBooks(t), 0)
+	require.Nil(t, err)
+
+	books, collections, err := handler.Purge(ctx, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Equal(t, int64(5), books)
+	require.Equal(t, int64(0), collections)
+
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{Deleted: apis.IncludeDeleted})
+	require.Nil(t, err)
+	require.Empty(t, page.Items)
+}
+
+func TestMembersCompareIsbnsByValue(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	for _, h := range []Handler{handler, NewMemoryHandler()} {
+		_, err = h.CreateBook(ctx, &apis.Book{Isbn: "7"})
+		require.Nil(t, err)
+		_, err = h.CreateCollection(ctx, &apis.Collection{Name: "Classics"})
+		require.Nil(t, err)
+
+		members := func() []string {
+			collections, err := h.GetCollection(ctx, allCollections(t), apis.ListOptions{})
+			require.Nil(t, err)
+			return collections.Items[0].Books
+		}
+
+		_, err = h.AddBookToCollection(ctx, "Classics", "007")
+		require.Nil(t, err)
+		require.Equal(t, []string{"7"}, members())
+
+		_, err = h.AddBookToCollection(ctx, "Classics", "7")
+		require.ErrorIs(t, err, ErrConflict)
+
+		_, err = h.RemoveBookFromCollection(ctx, "Classics", "0007")
+		require.Nil(t, err)
+		require.Empty(t, members())
+	}
+}
+
+func allCollections(t *testing.T) *apis.FilterChain {
+	filters, err := apis.ParseResourceFilters(apis.CollectionType, "description_ne_none")
+	require.Nil(t, err)
+	return filters
+}
+
+func TestHistory(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := WithPrincipal(context.Background(), "alice")
+	book := &apis.Book{Isbn: "1234567890987", Title: "Romeo and Juliet"}
+
+	_, err = handler.History(ctx, apis.BookType, book.Isbn)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = handler.CreateBook(ctx, book)
+	require.Nil(t, err)
+
+	book.Title = "Hamlet"
+	_, err = handler.UpdateBook(context.Background(), book)
+	require.Nil(t, err)
+
+	_, err = handler.CreateCollection(ctx, &apis.Collection{Name: "Classics", Books: []string{book.Isbn}})
+	require.Nil(t, err)
+
+	isbn, err := apis.ParseFilters("isbn_eq_1234567890987", apis.ValidateBookField, apis.ValidateBookValue)
+	require.Nil(t, err)
+
+	_, err = handler.DeleteBook(ctx, isbn, 0)
+	require.Nil(t, err)
+
+	_, err = handler.RestoreBook(ctx, book.Isbn)
+	require.Nil(t, err)
+
+	// failed changes are not recorded
+	_, err = handler.CreateBook(ctx, book)
+	require.ErrorIs(t, err, ErrConflict)
+
+	entries, err := handler.History(ctx, apis.BookType, book.Isbn)
+	require.Nil(t, err)
+	require.Len(t, entries, 4)
+
+	var ops []apis.HistoryOperation
+	for _, entry := range entries {
+		ops = append(ops, entry.Operation)
+		require.Equal(t, book.Isbn, entry.ID)
+		require.WithinDuration(t, time.Now(), entry.ChangedAt, time.Minute)
+	}
+	require.Equal(t, []apis.HistoryOperation{apis.HistoryCreate, apis.HistoryUpdate, apis.HistoryDelete, apis.HistoryRestore}, ops)
+
+	require.Equal(t, "alice", entries[0].Principal)
+	require.Nil(t, entries[0].Before)
+	require.Equal(t, anonymous, entries[1].Principal)
+	require.Equal(t, []apis.FieldChange{
+		{Field: "title", Before: "Romeo and Juliet", After: "Hamlet"},
+		{Field: "version", Before: json.Number("1"), After: json.Number("2")},
+	}, entries[1].Changes)
+	require.Equal(t, "deleted_at", entries[2].Changes[0].Field)
+
+	// members changes are recorded as updates of the collection
+	_, err = handler.RemoveBookFromCollection(ctx, "classics", book.Isbn)
+	require.Nil(t, err)
+
+	entries, err = handler.History(ctx, apis.CollectionType, "CLASSICS")
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, apis.HistoryUpdate, entries[1].Operation)
+	require.Equal(t, []apis.FieldChange{
+		{Field: "books", Before: []interface{}{book.Isbn}, After: []interface{}{}},
+		{Field: "version", Before: json.Number("1"), After: json.Number("2")},
+	}, entries[1].Changes)
+
+	// the history is kept once the book is purged
+	_, err = handler.DeleteBook(ctx, isbn, 0)
+	require.Nil(t, err)
+
+	_, _, err = handler.Purge(ctx, time.Now().Add(time.Hour))
+	require.Nil(t, err)
+
+	entries, err = handler.History(ctx, apis.BookType, book.Isbn)
+	require.Nil(t, err)
+	require.Len(t, entries, 6)
+	require.Equal(t, apis.HistoryPurge, entries[5].Operation)
+	require.Nil(t, entries[5].After)
+}
+
+func TestQueryError(t *testing.T) {
+	handler := &sqlHandler{classify: classifySQLiteError}
+
+	// the unexpected failures are typed, while their details are only logged
+	err := handler.queryError(context.Background(), "execute statement", errors.New("disk on fire"))
+	require.ErrorIs(t, err, ErrInternal)
+	require.NotContains(t, err.Error(), "disk on fire")
+}
+
+func TestMemoryHistoryFailure(t *testing.T) {
+	handler := NewMemoryHandler()
+	ctx := context.Background()
+
+	_, err := handler.CreateBook(ctx, &apis.Book{Isbn: "1"})
+	require.Nil(t, err)
+
+	// a state that cannot be recorded fails the change and leaves the history as it was
+	err = handler.record(ctx, apis.BookType, "2", apis.HistoryCreate, nil, make(chan int))
+	require.ErrorIs(t, err, ErrInternal)
+	require.Len(t, handler.history, 1)
+}
+
+func TestAsOf(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+
+	ctx := context.Background()
+	beforeAll := time.Now()
+
+	for _, isbn := range []string{"1", "2"} {
+		_, err = handler.CreateBook(ctx, &apis.Book{Isbn: isbn, Title: "Hamlet"})
+		require.Nil(t, err)
+	}
+
+	_, err = handler.CreateCollection(ctx, &apis.Collection{Name: "Classics", Books: []string{"1", "2"}})
+	require.Nil(t, err)
+	created := time.Now()
+
+	_, err = handler.UpdateBook(ctx, &apis.Book{Isbn: "1", Title: "Macbeth"})
+	require.Nil(t, err)
+
+	isbn2, err := apis.ParseFilters("isbn_eq_2", apis.ValidateBookField, apis.ValidateBookValue)
+	require.Nil(t, err)
+
+	_, err = handler.DeleteBook(ctx, isbn2, 0)
+	require.Nil(t, err)
+
+	page, err := handler.GetBook(ctx, allBooks(t), apis.ListOptions{AsOf: beforeAll})
+	require.Nil(t, err)
+	require.Empty(t, page.Items)
+
+	// the filters apply to the past state
+	hamlet, err := apis.ParseFilters("title_eq_hamlet", apis.ValidateBookField, apis.ValidateBookValue)
+	require.Nil(t, err)
+
+	page, err = handler.GetBook(ctx, hamlet, apis.ListOptions{AsOf: created})
+	require.Nil(t, err)
+	require.Equal(t, int64(2), page.Total)
+	require.Equal(t, uint64(1), page.Items[0].Version)
+
+	page, err = handler.GetBook(ctx, hamlet, apis.ListOptions{AsOf: time.Now()})
+	require.Nil(t, err)
+	require.Empty(t, page.Items)
+
+	page, err = handler.GetBook(ctx, hamlet, apis.ListOptions{AsOf: time.Now(), Deleted: apis.OnlyDeleted})
+	require.Nil(t, err)
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "2", page.Items[0].Isbn)
+
+	// the pages are selected by the database, the keys being ordered as numbers
+	_, err = handler.CreateBook(ctx, &apis.Book{Isbn: "10", Title: "Hamlet"})
+	require.Nil(t, err)
+
+	page, err = handler.GetBook(ctx, allBooks(t), apis.ListOptions{AsOf: time.Now(), Limit: 1, Cursor: "2"})
+	require.Nil(t, err)
+	require.Equal(t, int64(2), page.Total)
+	require.Equal(t, "10", page.Items[0].Isbn)
+	require.Empty(t, page.NextCursor)
+
+	page, err = handler.GetBook(ctx, allBooks(t), apis.ListOptions{AsOf: time.Now(), Deleted: apis.IncludeDeleted, Sort: []apis.SortField{{Field: "Title", Descending: true}}, Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, int64(3), page.Total)
+	require.Equal(t, []string{"1", "2"}, []string{page.Items[0].Isbn, page.Items[1].Isbn})
+
+	collections, err := handler.GetCollection(ctx, allCollections(t), apis.ListOptions{AsOf: created})
+	require.Nil(t, err)
+	require.Equal(t, []string{"1", "2"}, collections.Items[0].Books)
+
+	// the books in the trash are left out of the collections at that time too
+	collections, err = handler.GetCollection(ctx, allCollections(t), apis.ListOptions{AsOf: time.Now()})
+	require.Nil(t, err)
+	require.Equal(t, []string{"1"}, collections.Items[0].Books)
+}
+
+// TestRangeFilters ensures the ordering operators select the same books in SQL as in memory, numbers being compared by value,
+// both in the tables and in the past states
+func TestRangeFilters(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+	memory := NewMemoryHandler()
+
+	ctx := context.Background()
+	for i, edition := range []uint8{1, 2, 10} {
+		book := apis.Book{Isbn: strconv.Itoa(i + 1), Edition: edition, PublishedDate: apis.Date(time.Date(1850+i*50, 1, 1, 0, 0, 0, 0, time.UTC))}
+		_, err = handler.CreateBook(ctx, &book)
+		require.Nil(t, err)
+		_, err = memory.CreateBook(ctx, &book)
+		require.Nil(t, err)
+	}
+
+	for filter, desired := range map[string]int64{
+		"edition_gt_2":                 1,
+		"edition_ge_2":                 2,
+		"edition_lt_10":                2,
+		"edition_le_1":                 1,
+		"published-date_lt_1900-01-01": 1,
+		"published-date_ge_1900-01-01_and_edition_lt_10": 1,
+		"edition_in_1|10":                 2,
+		"edition_in_2|3_and_edition_ge_2": 1,
+		"edition_eq_1_or_edition_eq_10_and_published-date_lt_1950-01-01":   1,
+		"(edition_eq_1_or_edition_eq_10)_and_published-date_lt_1950-01-01": 1,
+		"isbn_in_1|3_and_edition_ge_2":                                     1,
+		"not_(edition_eq_1_or_edition_gt_5)":                               1,
+		"edition_le_2_and_not_edition_eq_1_or_edition_eq_10":               2,
+	} {
+		filters, err := apis.ParseFilters(filter, apis.ValidateBookField, apis.ValidateBookValue)
+		require.Nil(t, err)
+
+		for _, h := range []Handler{handler, memory} {
+			for _, opts := range []apis.ListOptions{{}, {AsOf: time.Now()}} {
+				page, err := h.GetBook(ctx, filters, opts)
+				require.Nil(t, err)
+				require.Equal(t, desired, page.Total, filter)
+			}
+		}
+	}
+}
+
+func TestPatternFilters(t *testing.T) {
+	handler, err := NewSQLiteHandler(Options{File: filepath.Join(t.TempDir(), "books.db")})
+	require.Nil(t, err)
+	defer handler.db.Close()
+	memory := NewMemoryHandler()
+
+	ctx := context.Background()
+	for i, title := range []string{"100% Pure", "1000 pure", "A_B", "axb", "Wow!"} {
+		book := apis.Book{Isbn: strconv.Itoa(i + 1), Title: title}
+		_, err = handler.CreateBook(ctx, &book)
+		require.Nil(t, err)
+		_, err = memory.CreateBook(ctx, &book)
+		require.Nil(t, err)
+	}
+
+	// the wildcards of the values are matched literally, ignoring the case
+	for _, tt := range []struct {
+		operation apis.Operator
+		value     string
+		desired   int64
+	}{
+		{apis.Contains, "0% p", 1},
+		{apis.Contains, "00", 2},
+		{apis.StartsWith, "a_", 1},
+		{apis.StartsWith, "a", 2},
+		{apis.EndsWith, "!", 1},
+		{apis.EndsWith, "PURE", 2},
+	} {
+		filters, err := apis.ParseFilters("edition_ge_0", apis.ValidateBookField, apis.ValidateBookValue)
+		require.Nil(t, err)
+		filters.And(&apis.Filter{Field: "Title", Operation: tt.operation, Value: tt.value})
+
+		for _, h := range []Handler{handler, memory} {
+			for _, opts := range []apis.ListOptions{{}, {AsOf: time.Now()}} {
+				page, err := h.GetBook(ctx, filters, opts)
+				require.Nil(t, err)
+				require.Equal(t, tt.desired, page.Total, filters.Canonical())
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"book-management/pkg/apis"
+	"database/sql"
+	"fmt"
+
+	// registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteHandler is the wrapper for a SQLite database stored in a single file
+type SQLiteHandler struct {
+	sqlHandler
+}
+
+// NewSQLiteHandler returns a new SQLiteHandler. The database file is created if it does not exist yet and the pending migrations are applied.
+func NewSQLiteHandler(opts Options) (*SQLiteHandler, error) {
+	db, err := openSQLite(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := newMigrator(db, apis.SQLite)
+	if err == nil {
+		_, err = migrator.Up()
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate db schema: %v", err)
+	}
+
+	handler := &SQLiteHandler{}
+	handler.db = db
+	handler.dialect = apis.SQLite
+	handler.classify = classifySQLiteError
+	handler.stateColumn = sqliteStateColumn
+	handler.timeout = opts.QueryTimeout
+	// the driver does not take transaction options: a SQLite transaction reads a single snapshot anyway
+
+	return handler, nil
+}
+
+// openSQLite opens the SQLite database file
+func openSQLite(opts Options) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(opts.File))
+	if err != nil {
+		return nil, fmt.Errorf("set up db connection: %v", err)
+	}
+
+	// SQLite serializes writers anyway: a single connection avoids SQLITE_BUSY errors
+	// and keeps in-memory databases shared across queries
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// sqliteDSN builds the data source name for the given database file
+func sqliteDSN(file string) string {
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", file)
+}
@@ -3,7 +3,10 @@ package rest
 import (
 	"book-management/pkg/apis"
 	"book-management/pkg/book-cli/pkg/options"
+	"book-management/pkg/server/pkg/db"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -19,14 +22,15 @@ func (s *BookServer) handleBookRetrieval(res http.ResponseWriter, req *http.Requ
 
 	if err != nil {
 		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while parsing filters: %v", err)).JSON(), http.StatusBadRequest)
+		return
 	}
 
 	switch req.Method {
-	// case options.Delete.String():
-	// 	s.DeleteBook(res, filters)
-	// 	break
+	case options.Delete.String():
+		s.DeleteBook(res, req, filters)
+		break
 	case options.Get.String():
-		s.GetBook(res, filters)
+		s.GetBook(res, req, filters)
 		break
 	}
 }
@@ -45,6 +49,95 @@ func (s *BookServer) handleBookModifications(res http.ResponseWriter, req *http.
 	}
 }
 
+// handleBookBatch handles the batch creation and update of books on the path /api/v1/books/batch.
+// The body is either a JSON array of books or a stream of newline delimited JSON books.
+func (s *BookServer) handleBookBatch(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	mode, err := apis.ParseBatchMode(req.URL.Query().Get("mode"))
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	books, err := apis.DecodeBooks(req.Body)
+	defer req.Body.Close()
+
+	if errors.Is(err, apis.ErrBatchTooLarge) {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while unmarshaling request body: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	var results []error
+	applied := apis.BatchCreated
+
+	switch req.Method {
+	case options.Create.String():
+		results, err = s.db.CreateBooks(req.Context(), books, mode)
+	case options.Update.String():
+		results, err = s.db.UpdateBooks(req.Context(), books, mode)
+		applied = apis.BatchUpdated
+	}
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while applying batch: %v", err)).JSON(), code)
+		return
+	}
+
+	report, code := batchReport(books, mode, results, applied)
+	msg, err := json.Marshal(report)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while marshaling batch report: %v", err)).JSON(), http.StatusInternalServerError)
+		return
+	}
+
+	if code != http.StatusOK {
+		failure := apis.NewError(code, fmt.Errorf("batch not applied"))
+		failure.Metadata = string(msg)
+		http.Error(res, failure.JSON(), code)
+		return
+	}
+
+	res.Write([]byte(apis.NewSuccess(string(msg)).JSON()))
+}
+
+// batchReport builds the report of a batch from the outcome of each book. The status code of an atomic batch that was not applied is the one of its first failure.
+func batchReport(books []apis.Book, mode apis.BatchMode, results []error, applied apis.BatchStatus) (*apis.BatchReport, int) {
+	report := &apis.BatchReport{
+		Mode:    mode,
+		Results: make([]apis.BatchResult, len(books)),
+	}
+	code := http.StatusOK
+
+	for i, err := range results {
+		report.Results[i].Isbn = books[i].Isbn
+
+		if err == nil {
+			report.Results[i].Status = applied
+			report.Succeeded++
+			continue
+		}
+
+		report.Results[i].Status = apis.BatchFailed
+		report.Results[i].Reason = err.Error()
+		report.Failed++
+
+		if mode == apis.Atomic && code == http.StatusOK && !errors.Is(err, db.ErrAborted) {
+			code = errorStatus(err)
+		}
+	}
+
+	return report, code
+}
+
 // CreateBook parses the request body and passes the object to the database driver
 func (s *BookServer) CreateBook(res http.ResponseWriter, req *http.Request) {
 	reqBody, err := ioutil.ReadAll(req.Body)
@@ -63,17 +156,27 @@ func (s *BookServer) CreateBook(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	msg, err := s.db.CreateBook(book)
+	msg, err := s.db.CreateBook(req.Context(), book)
 
 	if err != nil {
-		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while creating book: %v", err)).JSON(), http.StatusInternalServerError)
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while creating book: %v", err)).JSON(), code)
 		return
 	}
+	setETag(res, book.Version)
 	res.Write([]byte(apis.NewSuccess(msg).JSON()))
 }
 
-// UpdateBook parses the request body and passes the object to the database driver
+// UpdateBook parses the request body and passes the object to the database driver.
+// The book is only updated if it is still at the version of the If-Match header, when present. The version in the body is ignored.
 func (s *BookServer) UpdateBook(res http.ResponseWriter, req *http.Request) {
+	version, err := ifMatch(req)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
 	reqBody, err := ioutil.ReadAll(req.Body)
 	defer req.Body.Close()
 
@@ -90,31 +193,208 @@ func (s *BookServer) UpdateBook(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	msg, err := s.db.UpdateBook(book)
+	book.Version = version
+	msg, err := s.db.UpdateBook(req.Context(), book)
 
 	if err != nil {
-		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while updating book: %v", err)).JSON(), http.StatusInternalServerError)
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while updating book: %v", err)).JSON(), code)
 		return
 	}
+	setETag(res, book.Version)
 	res.Write([]byte(apis.NewSuccess(msg).JSON()))
 }
 
-// GetBook parses the request body and passes the object to the database driver
-func (s *BookServer) GetBook(res http.ResponseWriter, filters *apis.FilterChain) {
+// PatchBook updates the fields present in the request body of the book identified by the path, leaving the others unchanged.
+// The If-Match header is required: the fields are merged into the book as stored, provided it is at that version.
+func (s *BookServer) PatchBook(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	isbn := mux.Vars(req)["isbn"]
+	version, err := ifMatch(req)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	if version == 0 {
+		http.Error(res, apis.NewError(http.StatusPreconditionRequired, fmt.Errorf("patching a book requires the If-Match header with its ETag")).JSON(), http.StatusPreconditionRequired)
+		return
+	}
 
-	books, err := s.db.GetBook(filters)
+	reqBody, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
 
 	if err != nil {
-		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while getting books: %v", err)).JSON(), http.StatusInternalServerError)
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while reading request body: %v", err)).JSON(), http.StatusBadRequest)
 		return
 	}
 
-	msg, err := json.Marshal(books)
+	// the body is merged into the book as stored, rejecting the changes of the ISBN
+	var patchErr error
+	msg, book, err := s.db.PatchBook(req.Context(), isbn, version, func(book *apis.Book) error {
+		stored := book.Isbn
+		if patchErr = json.Unmarshal(reqBody, book); patchErr != nil {
+			patchErr = fmt.Errorf("error while unmarshaling request body: %v", patchErr)
+		} else if book.Isbn != stored {
+			patchErr = fmt.Errorf("error while patching book: the ISBN cannot be changed")
+		}
+		return patchErr
+	})
+
+	if patchErr != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, patchErr).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while patching book: %v", err)).JSON(), code)
+		return
+	}
+	setETag(res, book.Version)
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// ApplyBook parses the request body and creates the book, or updates it if it already exists.
+// A created book is reported with 201 and its location, a replaced one with 200.
+func (s *BookServer) ApplyBook(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while reading request body: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	book := &apis.Book{}
+	err = json.Unmarshal(reqBody, book)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while unmarshaling request body: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	book.Version = 0
+	msg, created, err := s.db.ApplyBook(req.Context(), book)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while applying book: %v", err)).JSON(), code)
+		return
+	}
+	setETag(res, book.Version)
+
+	if !created {
+		res.Write([]byte(apis.NewSuccess(msg).JSON()))
+		return
+	}
+
+	success := apis.NewSuccess(msg)
+	success.Code = http.StatusCreated
+	res.Header().Set("Location", "/api/v1/books?filter=isbn_eq_"+apis.EncodeFilterValue(book.Isbn))
+	res.WriteHeader(http.StatusCreated)
+	res.Write([]byte(success.JSON()))
+}
+
+// GetBook parses the pagination options and passes them to the database driver together with the filters.
+// When a single book matches, its version is returned as ETag, unless the books are listed as they were in the past.
+func (s *BookServer) GetBook(res http.ResponseWriter, req *http.Request, filters *apis.FilterChain) {
+	opts, err := apis.ParseListOptions(req.URL.Query(), "Isbn", apis.ValidateBookSortField)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while parsing pagination: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.db.GetBook(req.Context(), filters, opts)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while getting books: %v", err)).JSON(), code)
+		return
+	}
+
+	msg, err := json.Marshal(page)
 
 	if err != nil {
 		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while marshaling books: %v", err)).JSON(), http.StatusInternalServerError)
 		return
 	}
 
+	if page.Total == 1 && len(page.Items) == 1 && opts.AsOf.IsZero() {
+		setETag(res, page.Items[0].Version)
+	}
 	res.Write([]byte(apis.NewSuccess(string(msg)).JSON()))
 }
+
+// DeleteBook passes the filters to the database driver and reports how many books were deleted.
+// With an If-Match header the filters must match a single book, which is only deleted if it is still at that version.
+func (s *BookServer) DeleteBook(res http.ResponseWriter, req *http.Request, filters *apis.FilterChain) {
+	version, err := ifMatch(req)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := s.db.DeleteBook(req.Context(), filters, version)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while deleting books: %v", err)).JSON(), code)
+		return
+	}
+
+	res.Write([]byte(apis.NewSuccess(fmt.Sprintf("Deleted %d book(s)", deleted)).JSON()))
+}
+
+// RestoreBook moves the book identified by the path out of the trash
+func (s *BookServer) RestoreBook(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	msg, err := s.db.RestoreBook(req.Context(), mux.Vars(req)["isbn"])
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while restoring book: %v", err)).JSON(), code)
+		return
+	}
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// BookHistory returns the changes of the book identified by the path
+func (s *BookServer) BookHistory(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	s.writeHistory(res, req, apis.BookType, mux.Vars(req)["isbn"])
+}
+
+// errorStatus returns the HTTP status code corresponding to an error of the database handler
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, db.ErrAmbiguous):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, db.ErrInternal):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,547 @@
+package rest
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/server/pkg/db"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testBook = `{
+	"title": "Romeo and Juliet",
+	"author": "William Shakespeare",
+	"isbn": "1234567890987",
+	"published_date": "2000-01-02",
+	"edition": 1,
+	"description": "A love story in Verona",
+	"genre": "Drama"
+}`
+
+// timeoutHandler simulates a database whose queries exceed the deadline
+type timeoutHandler struct {
+	*db.MemoryHandler
+}
+
+func (timeoutHandler) GetBook(ctx context.Context, filters *apis.FilterChain, opts apis.ListOptions) (*apis.BookPage, error) {
+	return nil, fmt.Errorf("execute statement: %w", context.DeadlineExceeded)
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return newTestServerWithHandler(t, db.NewMemoryHandler())
+}
+
+func newTestServerWithHandler(t *testing.T, handler db.Handler) *httptest.Server {
+	s := newBookServer(handler)
+	ts := httptest.NewServer(s.server.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doRequest(t *testing.T, method string, url string, body string) (int, apis.Message) {
+	code, msg, _ := doConditionalRequest(t, method, url, body, "")
+	return code, msg
+}
+
+// doConditionalRequest sends the request with the If-Match header, if not empty, and returns the ETag of the response too
+func doConditionalRequest(t *testing.T, method string, url string, body string, ifMatch string) (int, apis.Message, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.Nil(t, err)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(res.Body)
+	require.Nil(t, err)
+
+	msg := apis.Message{}
+	require.Nil(t, json.Unmarshal(raw, &msg))
+	return res.StatusCode, msg, res.Header.Get("ETag")
+}
+
+func TestBookLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+
+	code, _ := doRequest(t, http.MethodPost, booksURL, testBook)
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg := doRequest(t, http.MethodPost, booksURL, testBook)
+	require.Equal(t, http.StatusConflict, code)
+	require.Equal(t, apis.CodeConflict, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "1234567890987", "1234567890986", 1))
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPost, booksURL, strings.Replace(testBook, "1234567890987", "not-an-isbn", 1))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, apis.CodeValidation, msg.Error)
+
+	code, _ = doRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, `"edition": 1`, `"edition": 2`, 1))
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=author_eq_william-shakespeare_and_edition_eq_2", "")
+	require.Equal(t, http.StatusOK, code)
+
+	page := apis.BookPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "1234567890987", page.Items[0].Isbn)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=edition_in_1|2", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=not_(edition_eq_2_or_author_eq_dante)", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 0)
+
+	// the encoded values keep their hyphens, underscores and capitals
+	code, msg = doRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "William Shakespeare", "Jean-Paul O'Brien_Sartre", 1))
+	require.Equal(t, http.StatusOK, code)
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter="+url.QueryEscape("author_eq_"+apis.EncodeFilterValue("Jean-Paul O'Brien_Sartre")), "")
+	require.Equal(t, http.StatusOK, code)
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=edition_eq_william", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, apis.CodeBadRequest, msg.Error)
+}
+
+func TestQueryTimeout(t *testing.T) {
+	ts := newTestServerWithHandler(t, timeoutHandler{db.NewMemoryHandler()})
+
+	code, msg := doRequest(t, http.MethodGet, ts.URL+"/api/v1/books?filter=isbn_eq_1234567890987", "")
+	require.Equal(t, http.StatusGatewayTimeout, code)
+	require.Equal(t, http.StatusGatewayTimeout, msg.Code)
+}
+
+func TestDeleteBook(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+
+	for _, isbn := range []string{"1", "2", "3"} {
+		code, _ := doRequest(t, http.MethodPost, booksURL, strings.Replace(testBook, "1234567890987", isbn, 1))
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	code, msg := doRequest(t, http.MethodDelete, booksURL+"?filter=isbn_eq_1", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Deleted 1 book(s)", msg.Metadata)
+
+	code, msg = doRequest(t, http.MethodDelete, booksURL+"?filter=author_eq_william-shakespeare", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Deleted 2 book(s)", msg.Metadata)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=author_eq_william-shakespeare", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[],"total":0}`, msg.Metadata)
+}
+
+func TestGetBookPagination(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+
+	for i, isbn := range []string{"30", "4", "200", "1000"} {
+		book := strings.Replace(testBook, "1234567890987", isbn, 1)
+		book = strings.Replace(book, `"edition": 1`, fmt.Sprintf(`"edition": %d`, i%2+1), 1)
+		code, _ := doRequest(t, http.MethodPost, booksURL, book)
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	getPage := func(query string) apis.BookPage {
+		code, msg := doRequest(t, http.MethodGet, booksURL+"?filter=genre_eq_drama&"+query, "")
+		require.Equal(t, http.StatusOK, code, msg.Metadata)
+
+		page := apis.BookPage{}
+		require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+		return page
+	}
+
+	isbns := func(page apis.BookPage) (isbns []string) {
+		for _, book := range page.Items {
+			isbns = append(isbns, book.Isbn)
+		}
+		return
+	}
+
+	page := getPage("limit=3")
+	require.Equal(t, []string{"4", "30", "200"}, isbns(page))
+	require.Equal(t, int64(4), page.Total)
+	require.Equal(t, "200", page.NextCursor)
+
+	page = getPage("limit=3&cursor=" + page.NextCursor)
+	require.Equal(t, []string{"1000"}, isbns(page))
+	require.Equal(t, "", page.NextCursor)
+
+	page = getPage("limit=2&offset=1&sort=-edition,isbn")
+	require.Equal(t, []string{"1000", "30"}, isbns(page))
+	require.Equal(t, "", page.NextCursor)
+
+	code, _ := doRequest(t, http.MethodGet, booksURL+"?filter=genre_eq_drama&sort=-edition&cursor=4", "")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, http.MethodGet, booksURL+"?filter=genre_eq_drama&sort=unknown", "")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, http.MethodGet, booksURL+"?filter=genre_eq_drama&sort=deleted_at&include_deleted=true", "")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestBookBatch(t *testing.T) {
+	ts := newTestServer(t)
+	batchURL := ts.URL + "/api/v1/books/batch"
+
+	ndjson := strings.Join([]string{
+		strings.ReplaceAll(strings.Replace(testBook, "1234567890987", "1", 1), "\n", ""),
+		strings.ReplaceAll(strings.Replace(testBook, "1234567890987", "2", 1), "\n", ""),
+	}, "\n")
+
+	code, msg := doRequest(t, http.MethodPost, batchURL, ndjson)
+	require.Equal(t, http.StatusOK, code, msg.Metadata)
+
+	report := apis.BatchReport{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &report))
+	require.Equal(t, apis.Atomic, report.Mode)
+	require.Equal(t, 2, report.Succeeded)
+	require.Equal(t, apis.BatchCreated, report.Results[1].Status)
+
+	array := "[" + strings.Replace(testBook, "1234567890987", "2", 1) + "," + strings.Replace(testBook, "1234567890987", "3", 1) + "]"
+
+	code, msg = doRequest(t, http.MethodPost, batchURL, array)
+	require.Equal(t, http.StatusConflict, code)
+	require.Equal(t, apis.CodeConflict, msg.Error)
+
+	report = apis.BatchReport{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &report))
+	require.Equal(t, 2, report.Failed)
+	require.Equal(t, apis.BatchFailed, report.Results[1].Status)
+
+	code, msg = doRequest(t, http.MethodPost, batchURL+"?mode=best-effort", array)
+	require.Equal(t, http.StatusOK, code)
+
+	report = apis.BatchReport{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &report))
+	require.Equal(t, []apis.BatchResult{
+		{Isbn: "2", Status: apis.BatchFailed, Reason: "conflict: book with ISBN 2 already exists"},
+		{Isbn: "3", Status: apis.BatchCreated},
+	}, report.Results)
+
+	code, msg = doRequest(t, http.MethodPut, batchURL, array)
+	require.Equal(t, http.StatusOK, code)
+
+	report = apis.BatchReport{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &report))
+	require.Equal(t, apis.BatchUpdated, report.Results[0].Status)
+
+	code, _ = doRequest(t, http.MethodPost, batchURL+"?mode=sometimes", array)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, http.MethodPost, batchURL, "[{")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// the decoding stops at the first book beyond the limit, whatever follows
+	for _, body := range []string{
+		"[" + strings.Repeat("{},", apis.MaxBatchSize) + "{}, not json",
+		strings.Repeat("{}\n", apis.MaxBatchSize) + "{}\n not json",
+	} {
+		code, msg = doRequest(t, http.MethodPost, batchURL, body)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Contains(t, msg.Metadata, apis.ErrBatchTooLarge.Error())
+	}
+}
+
+func TestApplyBook(t *testing.T) {
+	ts := newTestServer(t)
+	applyURL := ts.URL + "/api/v1/books/apply"
+
+	res, err := http.Post(applyURL, "application/json", strings.NewReader(testBook))
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.Equal(t, "/api/v1/books?filter=isbn_eq_1234567890987", res.Header.Get("Location"))
+
+	code, msg := doRequest(t, http.MethodGet, ts.URL+res.Header.Get("Location"), "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, msg.Metadata, `"isbn":"1234567890987"`)
+
+	code, msg = doRequest(t, http.MethodPost, applyURL, strings.Replace(testBook, "1234567890987", "1234567890986", 1))
+	require.Equal(t, http.StatusCreated, code)
+	require.True(t, strings.HasPrefix(msg.Metadata, "Created book"), msg.Metadata)
+
+	code, msg = doRequest(t, http.MethodPost, applyURL, strings.Replace(testBook, `"edition": 1`, `"edition": 2`, 1))
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, strings.HasPrefix(msg.Metadata, "Updated book"), msg.Metadata)
+
+	code, msg = doRequest(t, http.MethodPost, applyURL, strings.Replace(testBook, "1234567890987", "not-an-isbn", 1))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, apis.CodeValidation, msg.Error)
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+	bookURL := booksURL + "?filter=isbn_eq_1234567890987"
+
+	code, _, etag := doConditionalRequest(t, http.MethodPost, booksURL, testBook, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `"1"`, etag)
+
+	code, msg, etag := doConditionalRequest(t, http.MethodGet, bookURL, "", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `"1"`, etag)
+	page := apis.BookPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Equal(t, uint64(1), page.Items[0].Version)
+
+	// the first librarian updates the book read at version 1
+	code, _, etag = doConditionalRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "Drama", "Tragedy", 1), `"1"`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `"2"`, etag)
+
+	// the second one read the same version, so the update is rejected
+	code, msg, _ = doConditionalRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "Drama", "Comedy", 1), `W/"1"`)
+	require.Equal(t, http.StatusPreconditionFailed, code)
+	require.Equal(t, apis.CodePreconditionFailed, msg.Error)
+
+	code, _, _ = doConditionalRequest(t, http.MethodPut, booksURL, testBook, "not-a-version")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// updates without If-Match are unconditional
+	code, _, etag = doConditionalRequest(t, http.MethodPut, booksURL, testBook, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `"3"`, etag)
+
+	code, _, _ = doConditionalRequest(t, http.MethodDelete, bookURL, "", `"2"`)
+	require.Equal(t, http.StatusPreconditionFailed, code)
+
+	code, _ = doRequest(t, http.MethodPost, booksURL, strings.Replace(testBook, "1234567890987", "1234567890986", 1))
+	require.Equal(t, http.StatusOK, code)
+	code, _, _ = doConditionalRequest(t, http.MethodDelete, booksURL+"?filter=author_eq_william-shakespeare", "", `"3"`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, msg, _ = doConditionalRequest(t, http.MethodDelete, bookURL, "", `"3"`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Deleted 1 book(s)", msg.Metadata)
+
+	code, _, _ = doConditionalRequest(t, http.MethodDelete, bookURL, "", `"3"`)
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPatchBook(t *testing.T) {
+	ts := newTestServer(t)
+	bookURL := ts.URL + "/api/v1/books/1234567890987"
+
+	code, _, _ := doConditionalRequest(t, http.MethodPatch, bookURL, `{"genre": "Tragedy"}`, `"1"`)
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = doRequest(t, http.MethodPost, ts.URL+"/api/v1/books", testBook)
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg, _ := doConditionalRequest(t, http.MethodPatch, bookURL, `{"genre": "Tragedy"}`, "")
+	require.Equal(t, http.StatusPreconditionRequired, code)
+	require.Equal(t, apis.CodePreconditionRequired, msg.Error)
+
+	code, _, etag := doConditionalRequest(t, http.MethodPatch, bookURL, `{"genre": "Tragedy", "version": 7}`, `"1"`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `"2"`, etag)
+
+	code, _, _ = doConditionalRequest(t, http.MethodPatch, bookURL, `{"edition": 2}`, `"1"`)
+	require.Equal(t, http.StatusPreconditionFailed, code)
+
+	code, _, _ = doConditionalRequest(t, http.MethodPatch, bookURL, `{"isbn": "1234567890986"}`, `"2"`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// the fields missing from the body are left unchanged
+	code, msg, _ = doConditionalRequest(t, http.MethodGet, ts.URL+"/api/v1/books?filter=isbn_eq_1234567890987", "", "")
+	require.Equal(t, http.StatusOK, code)
+	page := apis.BookPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Equal(t, "Tragedy", page.Items[0].Genre)
+	require.Equal(t, "Romeo and Juliet", page.Items[0].Title)
+	require.Equal(t, uint64(2), page.Items[0].Version)
+}
+
+func TestRestoreBook(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+	collectionsURL := ts.URL + "/api/v1/collections"
+	createBooks(t, ts, "1234567890987", "30")
+
+	code, _ := doRequest(t, http.MethodPost, collectionsURL, testCollection)
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg := doRequest(t, http.MethodDelete, booksURL+"?filter=isbn_eq_30", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Deleted 1 book(s)", msg.Metadata)
+
+	for query, isbns := range map[string][]string{
+		"":                      {"1234567890987"},
+		"&include_deleted=true": {"30", "1234567890987"},
+		"&only_deleted=true":    {"30"},
+	} {
+		code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=title_eq_romeo-and-juliet"+query, "")
+		require.Equal(t, http.StatusOK, code)
+
+		page := apis.BookPage{}
+		require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+		require.Len(t, page.Items, len(isbns))
+		for i, isbn := range isbns {
+			require.Equal(t, isbn, page.Items[i].Isbn)
+			require.Equal(t, isbn == "30", page.Items[i].DeletedAt != nil)
+		}
+	}
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=name_eq_classics", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, msg.Metadata, `"books":["1234567890987"]`)
+
+	code, _ = doRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "1234567890987", "30", 1))
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = doRequest(t, http.MethodPost, booksURL+"/30/restore", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodPost, booksURL+"/30/restore", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=name_eq_classics", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, msg.Metadata, `"books":["30","1234567890987"]`)
+}
+
+func TestBookHistory(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+
+	code, _ := doRequest(t, http.MethodGet, booksURL+"/1234567890987/history", "")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = doRequest(t, http.MethodPost, booksURL, testBook)
+	require.Equal(t, http.StatusOK, code)
+
+	// the principal header is not trusted by default, while the credentials are recorded as digests
+	req, err := http.NewRequest(http.MethodPut, booksURL, strings.NewReader(strings.Replace(testBook, "Drama", "Tragedy", 1)))
+	require.Nil(t, err)
+	req.Header.Set(apis.PrincipalHeader, strings.Repeat("a", apis.MaxPrincipalLength+1))
+	req.Header.Set("Authorization", "Bearer secret-token")
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	code, msg := doRequest(t, http.MethodGet, booksURL+"/1234567890987/history", "")
+	require.Equal(t, http.StatusOK, code)
+
+	entries := []apis.HistoryEntry{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &entries))
+	require.Len(t, entries, 2)
+	require.Equal(t, apis.HistoryCreate, entries[0].Operation)
+	require.Equal(t, "anonymous", entries[0].Principal)
+	require.Equal(t, apis.HistoryUpdate, entries[1].Operation)
+	require.Equal(t, "token:"+digest("Bearer secret-token"), entries[1].Principal)
+	require.LessOrEqual(t, len(entries[1].Principal), apis.MaxPrincipalLength)
+	require.Equal(t, []apis.FieldChange{
+		{Field: "genre", Before: "Drama", After: "Tragedy"},
+		{Field: "version", Before: float64(1), After: float64(2)},
+	}, entries[1].Changes)
+}
+
+func TestTrustedPrincipalHeader(t *testing.T) {
+	s := newBookServer(db.NewMemoryHandler())
+	s.trustPrincipalHeader = true
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+	booksURL := ts.URL + "/api/v1/books"
+
+	create := func(principal string) int {
+		req, err := http.NewRequest(http.MethodPost, booksURL, strings.NewReader(testBook))
+		require.Nil(t, err)
+		req.Header.Set(apis.PrincipalHeader, principal)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		res, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	require.Equal(t, http.StatusBadRequest, create(strings.Repeat("a", apis.MaxPrincipalLength+1)))
+	require.Equal(t, http.StatusOK, create("alice"))
+
+	code, msg := doRequest(t, http.MethodGet, booksURL+"/1234567890987/history", "")
+	require.Equal(t, http.StatusOK, code)
+
+	entries := []apis.HistoryEntry{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &entries))
+	require.Len(t, entries, 1)
+	require.Equal(t, "alice", entries[0].Principal)
+}
+
+func TestClientIdentity(t *testing.T) {
+	anonymous := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/books", nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	// clients without credentials are told apart by their address, whatever their port
+	require.Equal(t, clientIdentity(anonymous("10.0.0.1:1234")), clientIdentity(anonymous("10.0.0.1:5678")))
+	require.NotEqual(t, clientIdentity(anonymous("10.0.0.1:1234")), clientIdentity(anonymous("10.0.0.2:1234")))
+
+	withSession := anonymous("10.0.0.1:1234")
+	withSession.AddCookie(&http.Cookie{Name: sessionCookie, Value: "secret-session"})
+	require.NotEqual(t, clientIdentity(anonymous("10.0.0.1:1234")), clientIdentity(withSession))
+	require.NotContains(t, clientIdentity(withSession), "secret-session")
+
+	withToken := anonymous("10.0.0.1:1234")
+	withToken.AddCookie(&http.Cookie{Name: sessionCookie, Value: "secret-session"})
+	withToken.Header.Set("Authorization", "Bearer secret-token")
+	require.NotEqual(t, clientIdentity(withSession), clientIdentity(withToken))
+	require.NotContains(t, clientIdentity(withToken), "secret-token")
+}
+
+func TestGetBookAsOf(t *testing.T) {
+	ts := newTestServer(t)
+	booksURL := ts.URL + "/api/v1/books"
+
+	code, _ := doRequest(t, http.MethodPost, booksURL, testBook)
+	require.Equal(t, http.StatusOK, code)
+	created := time.Now().UTC()
+
+	code, _ = doRequest(t, http.MethodPut, booksURL, strings.Replace(testBook, "Romeo and Juliet", "Hamlet", 1))
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg, etag := doConditionalRequest(t, http.MethodGet, booksURL+"?filter=title_eq_romeo-and-juliet&as_of="+created.Format(time.RFC3339Nano), "", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, etag)
+
+	page := apis.BookPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, uint64(1), page.Items[0].Version)
+
+	code, msg = doRequest(t, http.MethodGet, booksURL+"?filter=title_eq_romeo-and-juliet&as_of=2000-01-01", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Contains(t, msg.Metadata, `"total":0`)
+
+	code, _ = doRequest(t, http.MethodGet, booksURL+"?filter=title_eq_romeo-and-juliet&as_of=yesterday", "")
+	require.Equal(t, http.StatusBadRequest, code)
+}
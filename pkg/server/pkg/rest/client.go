@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"book-management/pkg/server/pkg/db"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+// sessionCookie is the cookie identifying the session of a client, as set by the authenticating proxy
+const sessionCookie = "session"
+
+// withClient passes the identity of the client to the database handler, which sends its reads to the primary for a while after its writes
+func withClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(res, req.WithContext(db.WithClient(req.Context(), clientIdentity(req))))
+	})
+}
+
+// clientIdentity tells apart the clients by their credentials, or else by their address.
+// The principal is not enough, as every client without credentials is anonymous.
+func clientIdentity(req *http.Request) string {
+	if identity := credentialIdentity(req); identity != "" {
+		return identity
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// credentialIdentity identifies the client by its bearer token or session cookie, which are only kept as digests.
+// It returns an empty string if the request has neither.
+func credentialIdentity(req *http.Request) string {
+	if token := req.Header.Get("Authorization"); token != "" {
+		return "token:" + digest(token)
+	}
+
+	if cookie, err := req.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return "session:" + digest(cookie.Value)
+	}
+	return ""
+}
+
+// digest returns the hex encoded SHA-256 of a credential, truncated to 128 bits so that the identities fit the principals of the history
+func digest(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/book-cli/pkg/options"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleCollectionRetrieval handles collection retrieval and deletion on the path /api/v1/collections/
+func (s *BookServer) handleCollectionRetrieval(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	filters, err := apis.ParseResourceFilters(apis.CollectionType, mux.Vars(req)["filter"])
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while parsing filters: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case options.Delete.String():
+		s.DeleteCollection(res, req, filters)
+	case options.Get.String():
+		s.GetCollection(res, req, filters)
+	}
+}
+
+// handleCollectionModifications handles the collection modifications on the path /api/v1/collections/
+func (s *BookServer) handleCollectionModifications(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while reading request body: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	collection := &apis.Collection{}
+	err = json.Unmarshal(reqBody, collection)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while unmarshaling request body: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case options.Create.String():
+		s.CreateCollection(res, req, collection)
+	case options.Update.String():
+		s.UpdateCollection(res, req, collection)
+	}
+}
+
+// handleCollectionMembers handles the membership of a book on the path /api/v1/collections/{name}/books/{isbn}
+func (s *BookServer) handleCollectionMembers(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	vars := mux.Vars(req)
+
+	switch req.Method {
+	case options.Create.String():
+		s.AddBookToCollection(res, req, vars["name"], vars["isbn"])
+	case options.Delete.String():
+		s.RemoveBookFromCollection(res, req, vars["name"], vars["isbn"])
+	}
+}
+
+// AddBookToCollection passes the membership to the database driver
+func (s *BookServer) AddBookToCollection(res http.ResponseWriter, req *http.Request, name string, isbn string) {
+	msg, err := s.db.AddBookToCollection(req.Context(), name, isbn)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while adding book to collection: %v", err)).JSON(), code)
+		return
+	}
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// RemoveBookFromCollection passes the membership to the database driver
+func (s *BookServer) RemoveBookFromCollection(res http.ResponseWriter, req *http.Request, name string, isbn string) {
+	msg, err := s.db.RemoveBookFromCollection(req.Context(), name, isbn)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while removing book from collection: %v", err)).JSON(), code)
+		return
+	}
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// CreateCollection passes the collection to the database driver
+func (s *BookServer) CreateCollection(res http.ResponseWriter, req *http.Request, collection *apis.Collection) {
+	msg, err := s.db.CreateCollection(req.Context(), collection)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while creating collection: %v", err)).JSON(), code)
+		return
+	}
+	setETag(res, collection.Version)
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// UpdateCollection passes the collection to the database driver.
+// The collection is only updated if it is still at the version of the If-Match header, when present. The version in the body is ignored.
+func (s *BookServer) UpdateCollection(res http.ResponseWriter, req *http.Request, collection *apis.Collection) {
+	version, err := ifMatch(req)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	collection.Version = version
+	msg, err := s.db.UpdateCollection(req.Context(), collection)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while updating collection: %v", err)).JSON(), code)
+		return
+	}
+	setETag(res, collection.Version)
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// GetCollection parses the pagination options and passes them to the database driver together with the filters.
+// When a single collection matches, its version is returned as ETag, unless the collections are listed as they were in the past.
+func (s *BookServer) GetCollection(res http.ResponseWriter, req *http.Request, filters *apis.FilterChain) {
+	opts, err := apis.ParseListOptions(req.URL.Query(), apis.CollectionType.Identifier(), apis.ValidateCollectionSortField)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("error while parsing pagination: %v", err)).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.db.GetCollection(req.Context(), filters, opts)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while getting collections: %v", err)).JSON(), code)
+		return
+	}
+
+	msg, err := json.Marshal(page)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while marshaling collections: %v", err)).JSON(), http.StatusInternalServerError)
+		return
+	}
+
+	if page.Total == 1 && len(page.Items) == 1 && opts.AsOf.IsZero() {
+		setETag(res, page.Items[0].Version)
+	}
+	res.Write([]byte(apis.NewSuccess(string(msg)).JSON()))
+}
+
+// DeleteCollection passes the filters to the database driver and reports how many collections were deleted.
+// With an If-Match header the filters must match a single collection, which is only deleted if it is still at that version.
+func (s *BookServer) DeleteCollection(res http.ResponseWriter, req *http.Request, filters *apis.FilterChain) {
+	version, err := ifMatch(req)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusBadRequest, err).JSON(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := s.db.DeleteCollection(req.Context(), filters, version)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while deleting collections: %v", err)).JSON(), code)
+		return
+	}
+
+	res.Write([]byte(apis.NewSuccess(fmt.Sprintf("Deleted %d collection(s)", deleted)).JSON()))
+}
+
+// RestoreCollection moves the collection identified by the path out of the trash
+func (s *BookServer) RestoreCollection(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	msg, err := s.db.RestoreCollection(req.Context(), mux.Vars(req)["name"])
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while restoring collection: %v", err)).JSON(), code)
+		return
+	}
+	res.Write([]byte(apis.NewSuccess(msg).JSON()))
+}
+
+// CollectionHistory returns the changes of the collection identified by the path
+func (s *BookServer) CollectionHistory(res http.ResponseWriter, req *http.Request) {
+	fmt.Printf("received new request. URL: %v Method: %v\n", req.URL, req.Method)
+
+	s.writeHistory(res, req, apis.CollectionType, mux.Vars(req)["name"])
+}
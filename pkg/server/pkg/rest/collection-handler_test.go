@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"book-management/pkg/apis"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCollection = `{
+	"name": "Classics",
+	"description": "Books everyone should read",
+	"creation_date": "2020-05-01",
+	"books": ["1234567890987", "30"]
+}`
+
+// createBooks creates a book for each ISBN
+func createBooks(t *testing.T, ts *httptest.Server, isbns ...string) {
+	for _, isbn := range isbns {
+		code, _ := doRequest(t, http.MethodPost, ts.URL+"/api/v1/books", strings.Replace(testBook, "1234567890987", isbn, 1))
+		require.Equal(t, http.StatusOK, code)
+	}
+}
+
+func TestCollectionLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	collectionsURL := ts.URL + "/api/v1/collections"
+	createBooks(t, ts, "1234567890987", "30", "4")
+
+	code, _ := doRequest(t, http.MethodPost, collectionsURL, testCollection)
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg := doRequest(t, http.MethodPost, collectionsURL, strings.Replace(testCollection, "Classics", "classics", 1))
+	require.Equal(t, http.StatusConflict, code)
+	require.Equal(t, apis.CodeConflict, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPost, collectionsURL, strings.Replace(testCollection, `"30"`, `"not-an-isbn"`, 1))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, apis.CodeValidation, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPut, collectionsURL, strings.Replace(testCollection, "Classics", "Unknown", 1))
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	code, _ = doRequest(t, http.MethodPut, collectionsURL, strings.Replace(testCollection, `"30"`, `"4"`, 1))
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=name_eq_classics", "")
+	require.Equal(t, http.StatusOK, code)
+
+	page := apis.CollectionPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, "Classics", page.Items[0].Name)
+	require.Equal(t, []string{"4", "1234567890987"}, page.Items[0].Books)
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=dates_eq_2021-01-01-to-2021-12-31", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, `{"items":[],"total":0}`, msg.Metadata)
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=author_eq_william-shakespeare", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, apis.CodeBadRequest, msg.Error)
+
+	code, msg = doRequest(t, http.MethodDelete, collectionsURL+"?filter=dates_eq_2020-01-01-to-2020-12-31", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Deleted 1 collection(s)", msg.Metadata)
+}
+
+func TestCollectionMembers(t *testing.T) {
+	ts := newTestServer(t)
+	collectionsURL := ts.URL + "/api/v1/collections"
+	createBooks(t, ts, "1234567890987", "30", "4")
+
+	code, msg := doRequest(t, http.MethodPost, collectionsURL, strings.Replace(testCollection, `"30"`, `"99"`, 1))
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, apis.CodeValidation, msg.Error)
+
+	code, _ = doRequest(t, http.MethodPost, collectionsURL, testCollection)
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = doRequest(t, http.MethodPost, collectionsURL+"/classics/books/4", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodPost, collectionsURL+"/Classics/books/4", "")
+	require.Equal(t, http.StatusConflict, code)
+	require.Equal(t, apis.CodeConflict, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPost, collectionsURL+"/Classics/books/99", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	code, msg = doRequest(t, http.MethodPost, collectionsURL+"/Unknown/books/4", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	code, _ = doRequest(t, http.MethodDelete, collectionsURL+"/Classics/books/30", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodDelete, collectionsURL+"/Classics/books/30", "")
+	require.Equal(t, http.StatusNotFound, code)
+	require.Equal(t, apis.CodeNotFound, msg.Error)
+
+	// deleting a book removes it from the collections
+	code, _ = doRequest(t, http.MethodDelete, ts.URL+"/api/v1/books?filter=isbn_eq_4", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg = doRequest(t, http.MethodGet, collectionsURL+"?filter=name_eq_classics", "")
+	require.Equal(t, http.StatusOK, code)
+
+	page := apis.CollectionPage{}
+	require.Nil(t, json.Unmarshal([]byte(msg.Metadata), &page))
+	require.Len(t, page.Items, 1)
+	require.Equal(t, []string{"1234567890987"}, page.Items[0].Books)
+	// created at version 1, then a book was added and one removed
+	require.Equal(t, uint64(3), page.Items[0].Version)
+
+	code, _ = doRequest(t, http.MethodPut, collectionsURL, testCollection)
+	require.Equal(t, http.StatusOK, code)
+
+	code, msg, _ = doConditionalRequest(t, http.MethodPut, collectionsURL, testCollection, `"3"`)
+	require.Equal(t, http.StatusPreconditionFailed, code)
+	require.Equal(t, apis.CodePreconditionFailed, msg.Error)
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setETag returns the version of a resource as its ETag. Versions start from 1, so zero means the version is unknown.
+func setETag(res http.ResponseWriter, version uint64) {
+	if version != 0 {
+		res.Header().Set("ETag", strconv.Quote(strconv.FormatUint(version, 10)))
+	}
+}
+
+// ifMatch returns the version required by the If-Match header of the request.
+// Zero means the request is unconditional, that is the header is missing or it is "*".
+func ifMatch(req *http.Request) (version uint64, err error) {
+	header := strings.TrimSpace(req.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if unquoted, err := strconv.Unquote(tag); err == nil {
+		tag = unquoted
+	}
+
+	version, err = strconv.ParseUint(tag, 10, 64)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid If-Match header %v: a single ETag returned by the server is expected", header)
+	}
+	return version, nil
+}
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"book-management/pkg/apis"
+	"book-management/pkg/server/pkg/db"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+// TrustPrincipalHeader makes the server record the principal named by the X-Principal header, which must then be set by an authenticating proxy
+var TrustPrincipalHeader bool
+
+func init() {
+	flag.BoolVar(&TrustPrincipalHeader, "trust-principal-header", false, "Record in the history the principal named by the X-Principal header. Enable it only behind a proxy authenticating the users and setting the header, since any client can set it otherwise.")
+}
+
+// withPrincipal passes the principal of the request to the database handler, which records it in the history of the changes.
+// The principal is the digest of the credentials of the client, or anonymous without them, see credentialIdentity.
+// The X-Principal header is only read if the server trusts it, as it names whoever the client wants.
+func (s *BookServer) withPrincipal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		principal := credentialIdentity(req)
+
+		if s.trustPrincipalHeader {
+			header := req.Header.Get(apis.PrincipalHeader)
+			if len(header) > apis.MaxPrincipalLength {
+				http.Error(res, apis.NewError(http.StatusBadRequest, fmt.Errorf("%v header cannot be longer than %d characters", apis.PrincipalHeader, apis.MaxPrincipalLength)).JSON(), http.StatusBadRequest)
+				return
+			}
+			if header != "" {
+				principal = header
+			}
+		}
+
+		next.ServeHTTP(res, req.WithContext(db.WithPrincipal(req.Context(), principal)))
+	})
+}
+
+// writeHistory writes the history of the resource, with the changed fields of each entry, as metadata of the response
+func (s *BookServer) writeHistory(res http.ResponseWriter, req *http.Request, kind apis.ResourceType, id string) {
+	entries, err := s.db.History(req.Context(), kind, id)
+
+	if err != nil {
+		code := errorStatus(err)
+		http.Error(res, apis.NewError(code, fmt.Errorf("error while getting %v history: %v", kind, err)).JSON(), code)
+		return
+	}
+
+	msg, err := json.Marshal(entries)
+
+	if err != nil {
+		http.Error(res, apis.NewError(http.StatusInternalServerError, fmt.Errorf("error while marshaling %v history: %v", kind, err)).JSON(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Write([]byte(apis.NewSuccess(string(msg)).JSON()))
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"book-management/pkg/apis"
 	"book-management/pkg/server/pkg/db"
 	"fmt"
 	"log"
@@ -13,28 +14,39 @@ import (
 type BookServer struct {
 	server http.Server
 	db     db.Handler
+	// trustPrincipalHeader records the principal named by the X-Principal header, see TrustPrincipalHeader
+	trustPrincipalHeader bool
 }
 
 // NewBookServer returns a new BookServer
 func NewBookServer() *BookServer {
-	b := &BookServer{}
-
 	dbOpts := db.NewDBOptions()
 
-	handler, err := db.NewMySQLHandler(dbOpts)
+	handler, err := db.NewHandler(dbOpts)
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	b.db = handler
+	b := newBookServer(handler)
+	b.trustPrincipalHeader = TrustPrincipalHeader
+	return b
+}
+
+// newBookServer returns a new BookServer backed by the supplied database handler
+func newBookServer(handler db.Handler) *BookServer {
+	b := &BookServer{
+		db: handler,
+	}
 
 	b.setupRESTSHandlers()
 	return b
 }
 
-// Start setup the DB connection and http handlers
+// Start setup the DB connection and http handlers. The connection is closed once the server stops.
 func (s *BookServer) Start() error {
+	defer s.db.Close()
+
 	fmt.Printf("starting server on %v", s.server.Addr)
 	return s.server.ListenAndServe()
 }
@@ -42,14 +54,47 @@ func (s *BookServer) Start() error {
 // setupRESTSHandlers setup REST handlers
 func (s *BookServer) setupRESTSHandlers() {
 	router := mux.NewRouter()
+	router.Use(s.withPrincipal, withClient)
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
 	subrouter.HandleFunc("/books", s.handleBookModifications).
 		Methods(http.MethodPost, http.MethodPut)
 
-	subrouter.NewRoute().Subrouter().Queries("filter", "{filter:[0-9|a-z|_|-]*}").
-		Methods(http.MethodDelete, http.MethodGet).
-		HandlerFunc(s.handleBookRetrieval)
+	subrouter.HandleFunc("/books/apply", s.ApplyBook).
+		Methods(http.MethodPost)
+
+	subrouter.HandleFunc("/books/batch", s.handleBookBatch).
+		Methods(http.MethodPost, http.MethodPut)
+
+	subrouter.HandleFunc("/books/{isbn}", s.PatchBook).
+		Methods(http.MethodPatch)
+
+	subrouter.HandleFunc("/books/{isbn}/restore", s.RestoreBook).
+		Methods(http.MethodPost)
+
+	subrouter.HandleFunc("/books/{isbn}/history", s.BookHistory).
+		Methods(http.MethodGet)
+
+	subrouter.HandleFunc("/books", s.handleBookRetrieval).
+		Queries("filter", "{filter:"+apis.FilterPattern+"}").
+		Methods(http.MethodDelete, http.MethodGet)
+
+	subrouter.HandleFunc("/collections", s.handleCollectionModifications).
+		Methods(http.MethodPost, http.MethodPut)
+
+	subrouter.HandleFunc("/collections", s.handleCollectionRetrieval).
+		Queries("filter", "{filter:"+apis.FilterPattern+"}").
+		Methods(http.MethodDelete, http.MethodGet)
+
+	subrouter.HandleFunc("/collections/{name}/books/{isbn}", s.handleCollectionMembers).
+		Methods(http.MethodPost, http.MethodDelete)
+
+	subrouter.HandleFunc("/collections/{name}/restore", s.RestoreCollection).
+		Methods(http.MethodPost)
+
+	subrouter.HandleFunc("/collections/{name}/history", s.CollectionHistory).
+		Methods(http.MethodGet)
+
 	s.server = http.Server{
 		Addr:    "127.0.0.1:8080",
 		Handler: router,
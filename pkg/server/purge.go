@@ -0,0 +1,37 @@
+package main
+
+import (
+	"book-management/pkg/server/pkg/db"
+	"context"
+	"fmt"
+	"time"
+)
+
+const purgeUsage = "usage: book-server [FLAGS] purge"
+
+// runPurge executes the purge subcommand, permanently removing the books and the collections kept in the trash longer than the retention
+func runPurge(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf(purgeUsage)
+	}
+
+	opts := db.NewDBOptions()
+	if opts.Driver == db.MemoryDriver {
+		return fmt.Errorf("db driver %v does not support purge: its data is lost when the server stops", opts.Driver)
+	}
+
+	handler, err := db.NewHandler(opts)
+	if err != nil {
+		return err
+	}
+	defer handler.Close()
+
+	before := time.Now().Add(-opts.TrashRetention)
+	books, collections, err := handler.Purge(context.Background(), before)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("purged %d book(s) and %d collection(s) deleted before %v\n", books, collections, before.Format("2006-01-02 15:04:05"))
+	return nil
+}